@@ -0,0 +1,150 @@
+package xmlrpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// Bind sets exported func fields of the struct pointed to by v to stubs
+// calling remote methods with CallContext:
+//
+//	var svc struct {
+//		Sum     func(a, b int) (int, error)        `xmlrpc:"service.sum"`
+//		Version func(ctx context.Context) (string, error)
+//		Reset   func() error
+//	}
+//	err := client.Bind(&svc)
+//
+// Method name is taken from the field's xmlrpc tag. Without a tag, it is the
+// field name, where underscores separate name parts and first letter of each
+// part is lowercased, e.g. System_ListMethods calls system.listMethods.
+// Fields tagged with "-" are skipped.
+//
+// The func may accept context.Context as its first parameter, other
+// parameters are sent as params of the call. It must return error as its
+// last result, which may be preceded by a result the response is decoded
+// into.
+func (client *Client) Bind(v interface{}) error {
+	val := reflect.ValueOf(v)
+	if val.Kind() != reflect.Ptr || val.IsNil() || val.Elem().Kind() != reflect.Struct {
+		return errors.New("xmlrpc: Bind requires non-nil pointer to struct")
+	}
+
+	val = val.Elem()
+	typ := val.Type()
+
+	for i := 0; i < typ.NumField(); i++ {
+		f := typ.Field(i)
+		if f.PkgPath != "" || f.Type.Kind() != reflect.Func {
+			continue
+		}
+
+		tag, _ := parseTag(f.Tag.Get("xmlrpc"))
+		if tag == "-" {
+			continue
+		}
+
+		method := tag
+		if method == "" {
+			method = bindMethodName(f.Name)
+		}
+
+		stub, err := client.makeStub(method, f.Type)
+		if err != nil {
+			return fmt.Errorf("xmlrpc: can't bind %s.%s: %v", typ, f.Name, err)
+		}
+
+		val.Field(i).Set(stub)
+	}
+
+	return nil
+}
+
+// makeStub returns func of type typ calling method.
+func (client *Client) makeStub(method string, typ reflect.Type) (reflect.Value, error) {
+	withContext := typ.NumIn() > 0 && typ.In(0) == contextType
+
+	switch {
+	case typ.NumOut() == 0 || typ.NumOut() > 2:
+		return reflect.Value{}, errors.New("func must return error or a value and error")
+	case typ.Out(typ.NumOut()-1) != errorType:
+		return reflect.Value{}, errors.New("last result of func must be error")
+	}
+
+	var replyType reflect.Type
+	if typ.NumOut() == 2 {
+		replyType = typ.Out(0)
+	}
+
+	stub := func(in []reflect.Value) []reflect.Value {
+		ctx := context.Background()
+		if withContext {
+			if c, ok := in[0].Interface().(context.Context); ok && c != nil {
+				ctx = c
+			}
+			in = in[1:]
+		}
+
+		if typ.IsVariadic() {
+			last := in[len(in)-1]
+			in = in[:len(in)-1]
+			for i := 0; i < last.Len(); i++ {
+				in = append(in, last.Index(i))
+			}
+		}
+
+		var args interface{}
+		if len(in) > 0 {
+			params := make([]interface{}, len(in))
+			for i, arg := range in {
+				params[i] = arg.Interface()
+			}
+			args = params
+		}
+
+		var reply reflect.Value
+		var replyPtr interface{}
+		if replyType != nil {
+			reply = reflect.New(replyType)
+			replyPtr = reply.Interface()
+		}
+
+		errVal := reflect.Zero(errorType)
+		if err := client.CallContext(ctx, method, args, replyPtr); err != nil {
+			errVal = reflect.ValueOf(&err).Elem()
+			if replyType != nil {
+				reply = reflect.New(replyType)
+			}
+		}
+
+		if replyType == nil {
+			return []reflect.Value{errVal}
+		}
+		return []reflect.Value{reply.Elem(), errVal}
+	}
+
+	return reflect.MakeFunc(typ, stub), nil
+}
+
+// bindMethodName returns method name for func field name.
+func bindMethodName(name string) string {
+	parts := strings.Split(name, "_")
+	for i, part := range parts {
+		if part == "" {
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(part)
+		parts[i] = string(unicode.ToLower(r)) + part[size:]
+	}
+	return strings.Join(parts, ".")
+}
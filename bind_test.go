@@ -0,0 +1,98 @@
+package xmlrpc
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+)
+
+type libraryService struct {
+	Add            func(b book, count int) (book, error) `xmlrpc:"library.add"`
+	Library_Whoami func(ctx context.Context) ([]string, error)
+	Remove         func(title string) error             `xmlrpc:"library.remove"`
+	Export         func(...interface{}) ([]book, error) `xmlrpc:"library.export"`
+	Skipped        func() error                         `xmlrpc:"-"`
+	Title          string
+	unexported     func() error
+}
+
+func Test_clientBind(t *testing.T) {
+	ts := httptest.NewServer(newTestServer())
+	defer ts.Close()
+
+	client, err := NewClient(ts.URL, userTransport{})
+	if err != nil {
+		t.Fatalf("Can't create client: %v", err)
+	}
+	defer client.Close()
+
+	var svc libraryService
+	if err := client.Bind(&svc); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if svc.Skipped != nil || svc.unexported != nil {
+		t.Fatal("skipped fields are bound")
+	}
+
+	b, err := svc.Add(book{"War and Piece", 1}, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if b != (book{"War and Piece", 3}) {
+		t.Fatalf("unexpected result: %v", b)
+	}
+
+	who, err := svc.Library_Whoami(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(who) != 3 || who[1] != "library.whoami" {
+		t.Fatalf("unexpected result: %v", who)
+	}
+
+	if err := svc.Remove("War and Piece"); !IsMethodNotFound(err) {
+		t.Fatalf("expected method not found fault, got %v", err)
+	}
+
+	books, err := svc.Export()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(books) != 100 {
+		t.Fatalf("expected 100 books, got %d", len(books))
+	}
+}
+
+func Test_clientBindErrors(t *testing.T) {
+	client, err := NewClient("http://localhost:1", nil)
+	if err != nil {
+		t.Fatalf("Can't create client: %v", err)
+	}
+	defer client.Close()
+
+	var svc libraryService
+	if err := client.Bind(svc); err == nil {
+		t.Fatal("expected error for non-pointer, but didn't get it")
+	}
+
+	var noError struct {
+		Sum func(a, b int) int
+	}
+	if err := client.Bind(&noError); err == nil {
+		t.Fatal("expected error for func without error result, but didn't get it")
+	}
+}
+
+func Test_bindMethodName(t *testing.T) {
+	tests := map[string]string{
+		"Sum":                "sum",
+		"System_ListMethods": "system.listMethods",
+		"Library__Add":       "library..add",
+	}
+
+	for name, expected := range tests {
+		if got := bindMethodName(name); got != expected {
+			t.Errorf("bindMethodName(%q): expected %q, got %q", name, expected, got)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package xmlrpc
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/ianaindex"
+	"golang.org/x/text/transform"
+)
+
+// charsetAliases maps charset names, which are used by servers but aren't
+// registered by IANA, to registered names.
+var charsetAliases = map[string]string{
+	"cp1250":  "windows-1250",
+	"cp1251":  "windows-1251",
+	"cp1252":  "windows-1252",
+	"cp1253":  "windows-1253",
+	"cp1254":  "windows-1254",
+	"cp1255":  "windows-1255",
+	"cp1256":  "windows-1256",
+	"cp1257":  "windows-1257",
+	"cp1258":  "windows-1258",
+	"win1251": "windows-1251",
+	"latin-1": "iso-8859-1",
+	"koi8r":   "koi8-r",
+	"koi8u":   "koi8-u",
+}
+
+// unsupportedCharsetError is returned for charsets without known encoding.
+type unsupportedCharsetError string
+
+func (e unsupportedCharsetError) Error() string {
+	return fmt.Sprintf("unsupported charset %q", string(e))
+}
+
+// lookupCharset returns encoding for charset name, e.g. "windows-1251". Names
+// registered by IANA and common aliases are supported. It returns nil encoding
+// for UTF-8.
+func lookupCharset(name string) (encoding.Encoding, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if alias, ok := charsetAliases[name]; ok {
+		name = alias
+	}
+
+	if name == "utf-8" || name == "utf8" {
+		return nil, nil
+	}
+
+	e, err := ianaindex.IANA.Encoding(name)
+	if err != nil || e == nil {
+		return nil, unsupportedCharsetError(name)
+	}
+
+	return e, nil
+}
+
+// charsetName returns the preferred name registered by IANA for charset
+// name, e.g. "windows-1251" for "cp1251" or "ISO-8859-1" for "latin1".
+func charsetName(name string) (string, error) {
+	e, err := lookupCharset(name)
+	if err != nil {
+		return "", err
+	}
+	if e == nil {
+		return "UTF-8", nil
+	}
+
+	// not every charset has a preferred MIME name
+	if canonical, err := ianaindex.MIME.Name(e); err == nil {
+		return canonical, nil
+	}
+	canonical, err := ianaindex.IANA.Name(e)
+	if err != nil {
+		return "", unsupportedCharsetError(name)
+	}
+
+	return canonical, nil
+}
+
+// charsetReader converts input in charset to UTF-8. It is used by decoder
+// unless CharsetReader is set.
+func charsetReader(charset string, input io.Reader) (io.Reader, error) {
+	e, err := lookupCharset(charset)
+	if err != nil {
+		return nil, err
+	}
+	if e == nil {
+		return input, nil
+	}
+
+	return transform.NewReader(input, e.NewDecoder()), nil
+}
+
+// encodeCharset converts UTF-8 data to charset. It fails with EncodeError
+// for characters that can't be represented in charset.
+func encodeCharset(data []byte, charset string) ([]byte, error) {
+	e, err := lookupCharset(charset)
+	if err != nil {
+		return nil, EncodeError{Msg: err.Error()}
+	}
+	if e == nil {
+		return data, nil
+	}
+
+	out, err := e.NewEncoder().Bytes(data)
+	if err == nil {
+		return out, nil
+	}
+
+	// find the character that can't be encoded to report it
+	for i := 0; i < len(data); {
+		r, size := utf8.DecodeRune(data[i:])
+		if _, err := e.NewEncoder().Bytes(data[i : i+size]); err != nil {
+			return nil, EncodeError{Msg: fmt.Sprintf("character %q at offset %d can't be represented in %s", r, i, charset)}
+		}
+		i += size
+	}
+
+	return nil, EncodeError{Msg: err.Error()}
+}
+
+var (
+	bomUTF8    = []byte{0xef, 0xbb, 0xbf}
+	bomUTF16BE = []byte{0xfe, 0xff}
+	bomUTF16LE = []byte{0xff, 0xfe}
+
+	// declEncoding matches encoding attribute of XML declaration.
+	declEncoding = regexp.MustCompile(`\s+encoding\s*=\s*("[^"]*"|'[^']*')`)
+)
+
+// maxDeclSize is the number of bytes searched for XML declaration.
+const maxDeclSize = 256
+
+// bodyReader returns reader that converts HTTP body r to UTF-8. Charset is
+// taken from Charset option, byte order mark or charset parameter of
+// contentType, in that order. If none of them is present, body is returned
+// as is and charset of XML declaration is applied by decoder.
+func (d *Decoder) bodyReader(r io.Reader, contentType string) (io.Reader, error) {
+	br := bufio.NewReader(r)
+
+	charset := d.Charset
+	if charset == "" {
+		charset = detectBOM(br)
+	}
+	if charset == "" && contentType != "" {
+		if _, params, err := mime.ParseMediaType(contentType); err == nil {
+			charset = params["charset"]
+		}
+	}
+	if charset == "" {
+		return br, nil
+	}
+
+	e, err := lookupCharset(charset)
+	if err != nil {
+		return nil, err
+	}
+
+	var utf8Reader io.Reader = br
+	if e != nil {
+		utf8Reader = transform.NewReader(br, e.NewDecoder())
+	}
+
+	return stripDeclEncoding(utf8Reader)
+}
+
+// detectBOM consumes byte order mark from br and returns charset it denotes,
+// or empty string if br doesn't start with byte order mark.
+func detectBOM(br *bufio.Reader) string {
+	head, _ := br.Peek(len(bomUTF8))
+	switch {
+	case bytes.HasPrefix(head, bomUTF8):
+		br.Discard(len(bomUTF8))
+		return "utf-8"
+	case bytes.HasPrefix(head, bomUTF16BE):
+		br.Discard(len(bomUTF16BE))
+		return "utf-16be"
+	case bytes.HasPrefix(head, bomUTF16LE):
+		br.Discard(len(bomUTF16LE))
+		return "utf-16le"
+	}
+	return ""
+}
+
+// stripDeclEncoding removes encoding attribute from XML declaration at the
+// beginning of r, which has already been converted to UTF-8, so the decoder
+// doesn't convert it once again.
+func stripDeclEncoding(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+
+	head, err := br.Peek(maxDeclSize)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	start := len(head) - len(bytes.TrimLeft(head, " \t\r\n"))
+	if !bytes.HasPrefix(head[start:], []byte("<?xml")) {
+		return br, nil
+	}
+
+	end := bytes.Index(head, []byte("?>"))
+	if end < 0 {
+		return br, nil
+	}
+
+	decl := declEncoding.ReplaceAll(head[start:end+2], nil)
+	br.Discard(end + 2)
+
+	return io.MultiReader(bytes.NewReader(decl), br), nil
+}
@@ -0,0 +1,104 @@
+package xmlrpc
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+)
+
+const tolstoy = "Л.Н. Толстой - Война и Мир"
+
+func encodeTestBody(t *testing.T, decl string, charset string, bom []byte) []byte {
+	body := decl + "<methodResponse><params><param><value><string>" + tolstoy + "</string></value></param></params></methodResponse>"
+
+	var data []byte
+	var err error
+	switch charset {
+	case "windows-1251":
+		data, err = charmap.Windows1251.NewEncoder().Bytes([]byte(body))
+	case "koi8-r":
+		data, err = charmap.KOI8R.NewEncoder().Bytes([]byte(body))
+	case "utf-16le":
+		data, err = unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM).NewEncoder().Bytes([]byte(body))
+	default:
+		data = []byte(body)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return append(append([]byte{}, bom...), data...)
+}
+
+func Test_decoderBodyReader(t *testing.T) {
+	tests := []struct {
+		name        string
+		body        []byte
+		contentType string
+		charset     string
+	}{
+		{
+			name: "xml declaration",
+			body: encodeTestBody(t, `<?xml version="1.0" encoding="windows-1251"?>`, "windows-1251", nil),
+		},
+		{
+			name:        "content type",
+			body:        encodeTestBody(t, `<?xml version="1.0"?>`, "windows-1251", nil),
+			contentType: "text/xml; charset=windows-1251",
+		},
+		{
+			name:        "content type over xml declaration",
+			body:        encodeTestBody(t, `<?xml version="1.0" encoding="iso-8859-1"?>`, "koi8-r", nil),
+			contentType: `text/xml; charset="KOI8-R"`,
+		},
+		{
+			name:        "utf-8 bom",
+			body:        encodeTestBody(t, `<?xml version="1.0" encoding="windows-1251"?>`, "utf-8", bomUTF8),
+			contentType: "text/xml; charset=windows-1251",
+		},
+		{
+			name: "utf-16 bom",
+			body: encodeTestBody(t, `<?xml version="1.0" encoding="UTF-16"?>`, "utf-16le", bomUTF16LE),
+		},
+		{
+			name:        "override",
+			body:        encodeTestBody(t, "\n"+`<?xml version='1.0' encoding='utf-8'?>`, "windows-1251", nil),
+			contentType: "text/xml; charset=utf-8",
+			charset:     "cp1251",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dec := &Decoder{Charset: tt.charset}
+
+			r, err := dec.bodyReader(bytes.NewReader(tt.body), tt.contentType)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			body, err := ioutil.ReadAll(r)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			var s string
+			if err := dec.Unmarshal(body, &s); err != nil {
+				t.Fatalf("unmarshal error: %v", err)
+			}
+			if s != tolstoy {
+				t.Fatalf("unexpected value: %q", s)
+			}
+		})
+	}
+}
+
+func Test_decoderBodyReaderUnsupportedCharset(t *testing.T) {
+	_, err := new(Decoder).bodyReader(bytes.NewReader(nil), "text/xml; charset=klingon")
+	if err == nil {
+		t.Fatal("expected error, but didn't get it")
+	}
+}
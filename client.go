@@ -1,9 +1,9 @@
 package xmlrpc
 
 import (
+	"context"
 	"errors"
-	"fmt"
-	"io/ioutil"
+	"io"
 	"net/http"
 	"net/http/cookiejar"
 	"net/rpc"
@@ -13,6 +13,36 @@ import (
 
 type Client struct {
 	*rpc.Client
+
+	codec *clientCodec
+
+	// invoker runs interceptors around invoke.
+	invoker Invoker
+
+	// interceptors run around calls, including StreamArray.
+	interceptors []Interceptor
+}
+
+// ClientOptions holds optional settings for a Client created by
+// NewClientWithOptions.
+type ClientOptions struct {
+	// Encoder is used to encode method calls. If nil, calls are encoded
+	// with default options.
+	Encoder *Encoder
+
+	// Decoder is used to decode response values. If nil, responses are
+	// decoded with default options.
+	Decoder *Decoder
+
+	// DisableCompression makes the client ask for uncompressed responses
+	// with "Accept-Encoding: identity" header, which also keeps
+	// http.Transport from asking for gzip. Compressed responses are still
+	// decompressed.
+	DisableCompression bool
+
+	// Interceptors run around every call made by Call, CallContext and
+	// StreamArray, the first one is the outermost.
+	Interceptors []Interceptor
 }
 
 // clientCodec is rpc.ClientCodec interface implementation.
@@ -31,8 +61,24 @@ type clientCodec struct {
 	responses map[uint64]*http.Response
 	mutex     sync.Mutex
 
+	// errs holds places to store errors of active requests made by Client.Call,
+	// so they are returned with their types instead of rpc.ServerError.
+	errs map[uint64]*error
+
 	response Response
 
+	// err is the place to store error of the response being read.
+	err *error
+
+	// encoder encodes method calls.
+	encoder *Encoder
+
+	// decoder decodes response values.
+	decoder *Decoder
+
+	// disableCompression makes requests ask for uncompressed responses.
+	disableCompression bool
+
 	// ready presents channel, that is used to link request and it`s response.
 	ready chan uint64
 
@@ -40,13 +86,56 @@ type clientCodec struct {
 	close chan uint64
 }
 
+// callArgs holds args of a call made by Client.Call, its context and the
+// place to store its error.
+type callArgs struct {
+	ctx  context.Context
+	args interface{}
+	err  *error
+}
+
 func (codec *clientCodec) WriteRequest(request *rpc.Request, args interface{}) (err error) {
-	httpRequest, err := NewRequest(codec.url.String(), request.ServiceMethod, args)
+	ctx := context.Background()
+	var errp *error
+	if a, ok := args.(*callArgs); ok {
+		ctx, args, errp = a.ctx, a.args, a.err
+	}
+
+	httpResponse, err := codec.do(ctx, request.ServiceMethod, args)
 
 	if err != nil {
 		return err
 	}
 
+	codec.mutex.Lock()
+	codec.responses[request.Seq] = httpResponse
+	if errp != nil {
+		codec.errs[request.Seq] = errp
+	}
+	codec.mutex.Unlock()
+
+	codec.ready <- request.Seq
+
+	return nil
+}
+
+// do sends a method call to xmlrpc service and returns its HTTP response.
+// The call is encoded with options set by ctx, and the request is aborted
+// when ctx is done.
+func (codec *clientCodec) do(ctx context.Context, serviceMethod string, args interface{}) (*http.Response, error) {
+	httpRequest, err := codec.encoder.withContext(ctx).NewRequest(codec.url.String(), serviceMethod, args)
+
+	if err != nil {
+		return nil, err
+	}
+	httpRequest = httpRequest.WithContext(ctx)
+
+	if codec.disableCompression {
+		httpRequest.Header.Set("Accept-Encoding", "identity")
+	} else {
+		httpRequest.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+
 	if codec.cookies != nil {
 		for _, cookie := range codec.cookies.Cookies(codec.url) {
 			httpRequest.AddCookie(cookie)
@@ -57,20 +146,37 @@ func (codec *clientCodec) WriteRequest(request *rpc.Request, args interface{}) (
 	httpResponse, err = codec.httpClient.Do(httpRequest)
 
 	if err != nil {
-		return err
+		return nil, TransportError{Err: err}
 	}
 
 	if codec.cookies != nil {
 		codec.cookies.SetCookies(codec.url, httpResponse.Cookies())
 	}
 
-	codec.mutex.Lock()
-	codec.responses[request.Seq] = httpResponse
-	codec.mutex.Unlock()
+	return httpResponse, nil
+}
 
-	codec.ready <- request.Seq
+// responseBody returns reader of decompressed and converted to UTF-8 body of
+// httpResponse.
+func (codec *clientCodec) responseBody(httpResponse *http.Response) (io.Reader, error) {
+	r, err := decompressBody(httpResponse.Body, httpResponse.Header.Get("Content-Encoding"))
+	if err != nil {
+		return nil, readError(err)
+	}
 
-	return nil
+	if r, err = codec.decoder.bodyReader(r, httpResponse.Header.Get("Content-Type")); err != nil {
+		return nil, readError(err)
+	}
+
+	return r, nil
+}
+
+// fail sets error of response.
+func (codec *clientCodec) fail(response *rpc.Response, err error) {
+	response.Error = err.Error()
+	if codec.err != nil {
+		*codec.err = err
+	}
 }
 
 func (codec *clientCodec) ReadResponseHeader(response *rpc.Response) (err error) {
@@ -85,24 +191,32 @@ func (codec *clientCodec) ReadResponseHeader(response *rpc.Response) (err error)
 	codec.mutex.Lock()
 	httpResponse := codec.responses[seq]
 	delete(codec.responses, seq)
+	codec.err = codec.errs[seq]
+	delete(codec.errs, seq)
 	codec.mutex.Unlock()
 
 	defer httpResponse.Body.Close()
 
 	if httpResponse.StatusCode < 200 || httpResponse.StatusCode >= 300 {
-		response.Error = fmt.Sprintf("request error: bad status code - %d", httpResponse.StatusCode)
+		codec.fail(response, HTTPError{StatusCode: httpResponse.StatusCode})
+		return nil
+	}
+
+	r, err := codec.responseBody(httpResponse)
+	if err != nil {
+		codec.fail(response, err)
 		return nil
 	}
 
-	body, err := ioutil.ReadAll(httpResponse.Body)
+	body, err := codec.decoder.ReadBody(r)
 	if err != nil {
-		response.Error = err.Error()
+		codec.fail(response, readError(err))
 		return nil
 	}
 
 	resp := Response(body)
-	if err := resp.Err(); err != nil {
-		response.Error = err.Error()
+	if err := resp.err(codec.decoder); err != nil {
+		codec.fail(response, decodeError(err))
 		return nil
 	}
 
@@ -115,7 +229,15 @@ func (codec *clientCodec) ReadResponseBody(v interface{}) (err error) {
 	if v == nil {
 		return nil
 	}
-	return codec.response.Unmarshal(v)
+
+	if err = codec.decoder.Unmarshal(codec.response, v); err != nil {
+		err = DecodeError{Err: err}
+		if codec.err != nil {
+			*codec.err = err
+		}
+	}
+
+	return err
 }
 
 func (codec *clientCodec) Close() error {
@@ -130,6 +252,26 @@ func (codec *clientCodec) Close() error {
 
 // NewClient returns instance of rpc.Client object, that is used to send request to xmlrpc service.
 func NewClient(requrl string, transport http.RoundTripper) (*Client, error) {
+	return NewClientWithOptions(requrl, transport, nil)
+}
+
+// NewClientWithOptions works like NewClient, but allows to change default
+// client settings with opts. A nil opts is the same as calling NewClient.
+func NewClientWithOptions(requrl string, transport http.RoundTripper, opts *ClientOptions) (*Client, error) {
+	if opts == nil {
+		opts = &ClientOptions{}
+	}
+
+	encoder := opts.Encoder
+	if encoder == nil {
+		encoder = &Encoder{}
+	}
+
+	decoder := opts.Decoder
+	if decoder == nil {
+		decoder = &Decoder{}
+	}
+
 	if transport == nil {
 		transport = http.DefaultTransport
 	}
@@ -154,8 +296,82 @@ func NewClient(requrl string, transport http.RoundTripper) (*Client, error) {
 		close:      make(chan uint64),
 		ready:      make(chan uint64),
 		responses:  make(map[uint64]*http.Response),
+		errs:       make(map[uint64]*error),
 		cookies:    jar,
+		encoder:    encoder,
+		decoder:    decoder,
+
+		disableCompression: opts.DisableCompression,
+	}
+
+	client := &Client{Client: rpc.NewClientWithCodec(&codec), codec: &codec, interceptors: opts.Interceptors}
+	client.invoker = chainInterceptors(opts.Interceptors, client.invoke)
+
+	return client, nil
+}
+
+// StreamArray calls serviceMethod, which must return an array, and returns
+// ArrayDecoder that reads its elements directly from the response body. Unlike
+// Call, the response is not buffered. ArrayDecoder must be closed by caller.
+// Interceptors of the client are run around the call with nil reply.
+func (client *Client) StreamArray(serviceMethod string, args interface{}) (*ArrayDecoder, error) {
+	return client.StreamArrayContext(context.Background(), serviceMethod, args)
+}
+
+// StreamArrayContext works like StreamArray, but aborts the HTTP request when
+// ctx is done, including reading of the response body by ArrayDecoder. It
+// fails if interceptors return no error without a successful call of next, as
+// there is no response to read.
+func (client *Client) StreamArrayContext(ctx context.Context, serviceMethod string, args interface{}) (*ArrayDecoder, error) {
+	var arr *ArrayDecoder
+	invoker := chainInterceptors(client.interceptors, func(ctx context.Context, method string, args, reply interface{}) (err error) {
+		// interceptor may retry the call
+		if arr != nil {
+			arr.Close()
+		}
+		arr, err = client.streamArray(ctx, method, args)
+		return err
+	})
+
+	if err := invoker(ctx, serviceMethod, args, nil); err != nil {
+		if arr != nil {
+			arr.Close()
+		}
+		return nil, err
+	}
+
+	if arr == nil {
+		return nil, errors.New("xmlrpc: interceptor of StreamArray returned no result")
+	}
+
+	return arr, nil
+}
+
+// streamArray calls serviceMethod and returns ArrayDecoder reading its
+// result.
+func (client *Client) streamArray(ctx context.Context, serviceMethod string, args interface{}) (*ArrayDecoder, error) {
+	httpResponse, err := client.codec.do(ctx, serviceMethod, args)
+	if err != nil {
+		return nil, err
+	}
+
+	if httpResponse.StatusCode < 200 || httpResponse.StatusCode >= 300 {
+		httpResponse.Body.Close()
+		return nil, HTTPError{StatusCode: httpResponse.StatusCode}
+	}
+
+	r, err := client.codec.responseBody(httpResponse)
+	if err != nil {
+		httpResponse.Body.Close()
+		return nil, err
+	}
+
+	arr, err := client.codec.decoder.NewArrayDecoder(r)
+	if err != nil {
+		httpResponse.Body.Close()
+		return nil, decodeError(err)
 	}
+	arr.closer = httpResponse.Body
 
-	return &Client{rpc.NewClientWithCodec(&codec)}, nil
+	return arr, nil
 }
@@ -0,0 +1,695 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// annotation marks struct types to generate methods for.
+const annotation = "//xmlrpc:generate"
+
+// typeDecl is a type declared in the package.
+type typeDecl struct {
+	name string
+	typ  ast.Expr
+	file *ast.File
+}
+
+// pkgInfo holds type declarations of the package.
+type pkgInfo struct {
+	fset  *token.FileSet
+	types map[string]*typeDecl
+}
+
+// field describes how a struct field is represented as a member of XML-RPC
+// struct. It mirrors field of package xmlrpc.
+type field struct {
+	// name is the member name.
+	name string
+
+	// tagged reports that name comes from the field's xmlrpc tag.
+	tagged bool
+
+	// index is the sequence of field indexes leading to the field through
+	// embedded structs.
+	index []int
+
+	// path is the sequence of Go field names leading to the field.
+	path []string
+
+	// guards are embedded struct pointers on the path to the field.
+	guards []guard
+
+	typ  ast.Expr
+	file *ast.File
+
+	omitEmpty bool
+	required  bool
+
+	// opts holds all options of the field's xmlrpc tag.
+	opts string
+}
+
+// guard is an embedded struct pointer, which is nil checked on encoding and
+// allocated on decoding.
+type guard struct {
+	// expr is the selector of the pointer.
+	expr string
+
+	// typeName is the name of the struct type.
+	typeName string
+}
+
+// selector returns the expression selecting the field of v.
+func (f *field) selector() string {
+	return "v." + strings.Join(f.path, ".")
+}
+
+// generate returns source of methods for struct types declared in filename.
+// It returns nil if there are no types to generate methods for.
+func generate(filename string, all bool) ([]byte, error) {
+	fset := token.NewFileSet()
+
+	file, err := parser.ParseFile(fset, filename, nil, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+
+	pkg, err := parsePackage(fset, filepath.Dir(filename), file)
+	if err != nil {
+		return nil, err
+	}
+
+	g := &generator{pkg: pkg}
+	for _, decl := range file.Decls {
+		gd, ok := decl.(*ast.GenDecl)
+		if !ok || gd.Tok != token.TYPE {
+			continue
+		}
+
+		for _, spec := range gd.Specs {
+			ts := spec.(*ast.TypeSpec)
+			if _, ok := ts.Type.(*ast.StructType); !ok || ts.Assign.IsValid() {
+				continue
+			}
+			if !all && !annotated(gd.Doc) && !annotated(ts.Doc) {
+				continue
+			}
+
+			if err := g.generateType(pkg.types[ts.Name.Name]); err != nil {
+				return nil, fmt.Errorf("%s: %v", ts.Name.Name, err)
+			}
+		}
+	}
+
+	if g.body.Len() == 0 {
+		return nil, nil
+	}
+
+	var b bytes.Buffer
+	b.WriteString("// Code generated by xmlrpc-codegen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&b, "package %s\n\n", file.Name.Name)
+	b.WriteString("import (\n")
+	if g.imports["reflect"] {
+		b.WriteString("\t\"reflect\"\n")
+	}
+	if g.imports["time"] {
+		b.WriteString("\t\"time\"\n")
+	}
+	b.WriteString("\n\t\"github.com/kolo/xmlrpc\"\n)\n")
+	b.Write(g.body.Bytes())
+
+	return format.Source(b.Bytes())
+}
+
+// parsePackage collects type declarations from files of package of file in
+// dir, except test files and generated ones.
+func parsePackage(fset *token.FileSet, dir string, file *ast.File) (*pkgInfo, error) {
+	filter := func(fi os.FileInfo) bool {
+		name := fi.Name()
+		return !strings.HasSuffix(name, "_test.go") && !strings.HasSuffix(name, "_xmlrpc.go")
+	}
+
+	pkgs, err := parser.ParseDir(fset, dir, filter, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &pkgInfo{fset: fset, types: make(map[string]*typeDecl)}
+
+	files := []*ast.File{file}
+	if pkg, ok := pkgs[file.Name.Name]; ok {
+		for _, f := range pkg.Files {
+			files = append(files, f)
+		}
+	}
+
+	for i, f := range files {
+		for _, decl := range f.Decls {
+			gd, ok := decl.(*ast.GenDecl)
+			if !ok || gd.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gd.Specs {
+				ts := spec.(*ast.TypeSpec)
+				if _, ok := info.types[ts.Name.Name]; ok && i > 0 {
+					// the file itself has been parsed first
+					continue
+				}
+				info.types[ts.Name.Name] = &typeDecl{name: ts.Name.Name, typ: ts.Type, file: f}
+			}
+		}
+	}
+
+	return info, nil
+}
+
+// annotated reports whether doc holds the annotation.
+func annotated(doc *ast.CommentGroup) bool {
+	if doc == nil {
+		return false
+	}
+	for _, c := range doc.List {
+		if strings.TrimSpace(c.Text) == annotation {
+			return true
+		}
+	}
+	return false
+}
+
+// structDecl returns declaration of struct type expr in file refers to, if
+// it is declared in the package. Struct type literals are declarations named
+// after their source, which is the same for identical types.
+func (p *pkgInfo) structDecl(expr ast.Expr, file *ast.File) (*typeDecl, bool) {
+	if st, ok := expr.(*ast.StructType); ok {
+		var b bytes.Buffer
+		if err := format.Node(&b, p.fset, st); err != nil {
+			return nil, false
+		}
+		return &typeDecl{name: b.String(), typ: st, file: file}, true
+	}
+
+	ident, ok := expr.(*ast.Ident)
+	if !ok {
+		return nil, false
+	}
+
+	// follow definitions like "type A B"
+	for i := 0; i < 10; i++ {
+		decl, ok := p.types[ident.Name]
+		if !ok {
+			return nil, false
+		}
+		switch t := decl.typ.(type) {
+		case *ast.StructType:
+			return decl, true
+		case *ast.Ident:
+			ident = t
+		default:
+			return nil, false
+		}
+	}
+
+	return nil, false
+}
+
+// typeFields returns fields of struct type decl, that become XML-RPC struct
+// members, following the rules of typeFields of package xmlrpc.
+func (p *pkgInfo) typeFields(decl *typeDecl) ([]field, error) {
+	var current []field
+	next := []field{{typ: ast.NewIdent(decl.name), file: decl.file}}
+
+	var count, nextCount map[string]int
+
+	visited := map[string]bool{}
+
+	var fields []field
+
+	for len(next) > 0 {
+		current, next = next, current[:0]
+		count, nextCount = nextCount, map[string]int{}
+
+		for _, f := range current {
+			sd, _ := p.structDecl(f.typ, f.file)
+			if visited[sd.name] {
+				continue
+			}
+			visited[sd.name] = true
+
+			i := -1
+			for _, af := range sd.typ.(*ast.StructType).Fields.List {
+				names := af.Names
+				if len(names) == 0 {
+					names = []*ast.Ident{ast.NewIdent(embeddedName(af.Type))}
+				}
+
+				for _, ident := range names {
+					i++
+
+					ft := af.Type
+					ptr := false
+					if star, ok := ft.(*ast.StarExpr); ok {
+						ft, ptr = star.X, true
+					}
+
+					inner, isStruct := p.structDecl(ft, sd.file)
+					anonymous := len(af.Names) == 0
+
+					if anonymous {
+						// unexported embedded structs can still provide
+						// exported fields, unless they have to be allocated.
+						if !ast.IsExported(ident.Name) && (!isStruct || ptr) {
+							continue
+						}
+					} else if !ast.IsExported(ident.Name) {
+						continue
+					}
+
+					name, opts := parseTag(af.Tag)
+					if name == "-" {
+						continue
+					}
+
+					index := make([]int, len(f.index)+1)
+					copy(index, f.index)
+					index[len(f.index)] = i
+
+					path := make([]string, len(f.path)+1)
+					copy(path, f.path)
+					path[len(f.path)] = ident.Name
+
+					wantInline := hasOption(opts, "inline") || (anonymous && name == "")
+					if wantInline && !isStruct && !isTime(ft, sd.file) && isForeign(ft) {
+						return nil, fmt.Errorf("can't inline %s: struct types declared in other packages aren't supported", ident.Name)
+					}
+
+					if !isStruct || !wantInline {
+						tagged := name != ""
+						if name == "" {
+							name = ident.Name
+						}
+
+						fields = append(fields, field{
+							name:      name,
+							tagged:    tagged,
+							index:     index,
+							path:      path,
+							guards:    f.guards,
+							typ:       af.Type,
+							file:      sd.file,
+							omitEmpty: hasOption(opts, "omitempty"),
+							required:  hasOption(opts, "required"),
+							opts:      opts,
+						})
+						if count[sd.name] > 1 {
+							// the struct is embedded more than once on this
+							// level, add a duplicate so that the field is
+							// dropped below.
+							fields = append(fields, fields[len(fields)-1])
+						}
+						continue
+					}
+
+					guards := f.guards
+					if ptr {
+						typeName := inner.name
+						if ident, ok := ft.(*ast.Ident); ok {
+							typeName = ident.Name
+						}
+						guards = append(guards[:len(guards):len(guards)], guard{
+							expr:     "v." + strings.Join(path, "."),
+							typeName: typeName,
+						})
+					}
+
+					nextCount[inner.name]++
+					if nextCount[inner.name] == 1 {
+						next = append(next, field{index: index, path: path, guards: guards, typ: ft, file: sd.file})
+					}
+				}
+			}
+		}
+	}
+
+	sort.Slice(fields, func(i, j int) bool {
+		x := fields
+		if x[i].name != x[j].name {
+			return x[i].name < x[j].name
+		}
+		if len(x[i].index) != len(x[j].index) {
+			return len(x[i].index) < len(x[j].index)
+		}
+		if x[i].tagged != x[j].tagged {
+			return x[i].tagged
+		}
+		return indexLess(x[i].index, x[j].index)
+	})
+
+	// drop fields hidden by less nested or tagged fields with the same name.
+	out := fields[:0]
+	for advance, i := 0, 0; i < len(fields); i += advance {
+		fi := fields[i]
+		for advance = 1; i+advance < len(fields); advance++ {
+			if fields[i+advance].name != fi.name {
+				break
+			}
+		}
+		if advance == 1 {
+			out = append(out, fi)
+			continue
+		}
+		if dominant, ok := dominantField(fields[i : i+advance]); ok {
+			out = append(out, dominant)
+		}
+	}
+
+	fields = out
+	sort.Slice(fields, func(i, j int) bool { return indexLess(fields[i].index, fields[j].index) })
+
+	return fields, nil
+}
+
+// dominantField returns the field that hides other fields with the same name.
+func dominantField(fields []field) (field, bool) {
+	if len(fields) > 1 && len(fields[0].index) == len(fields[1].index) && fields[0].tagged == fields[1].tagged {
+		return field{}, false
+	}
+	return fields[0], true
+}
+
+func indexLess(a, b []int) bool {
+	for k, x := range a {
+		if k >= len(b) {
+			return false
+		}
+		if x != b[k] {
+			return x < b[k]
+		}
+	}
+	return len(a) < len(b)
+}
+
+// embeddedName returns field name of embedded type expr.
+func embeddedName(expr ast.Expr) string {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return embeddedName(t.X)
+	case *ast.SelectorExpr:
+		return t.Sel.Name
+	case *ast.Ident:
+		return t.Name
+	}
+	return ""
+}
+
+// parseTag returns name and options of xmlrpc tag of struct field.
+func parseTag(lit *ast.BasicLit) (string, string) {
+	if lit == nil {
+		return "", ""
+	}
+
+	tag, err := strconv.Unquote(lit.Value)
+	if err != nil {
+		return "", ""
+	}
+
+	s := reflect.StructTag(tag).Get("xmlrpc")
+	if idx := strings.Index(s, ","); idx != -1 {
+		return s[:idx], s[idx+1:]
+	}
+	return s, ""
+}
+
+// hasOption reports whether comma-separated options contain option.
+func hasOption(opts string, option string) bool {
+	for _, o := range strings.Split(opts, ",") {
+		if o == option {
+			return true
+		}
+	}
+	return false
+}
+
+// wireOptions reports whether opts contain options changing XML-RPC type of
+// the value, such as "string" or "format=...".
+func wireOptions(opts string) bool {
+	for _, o := range strings.Split(opts, ",") {
+		switch o {
+		case "", "omitempty", "required", "inline":
+		default:
+			return true
+		}
+	}
+	return false
+}
+
+// isTime reports whether expr is time.Time in file.
+func isTime(expr ast.Expr, file *ast.File) bool {
+	sel, ok := expr.(*ast.SelectorExpr)
+	if !ok || sel.Sel.Name != "Time" {
+		return false
+	}
+	x, ok := sel.X.(*ast.Ident)
+	return ok && x.Name == importName(file, "time")
+}
+
+// isForeign reports whether expr is a type declared in another package.
+func isForeign(expr ast.Expr) bool {
+	_, ok := expr.(*ast.SelectorExpr)
+	return ok
+}
+
+// importName returns name of package path imported by file.
+func importName(file *ast.File, path string) string {
+	for _, imp := range file.Imports {
+		if p, _ := strconv.Unquote(imp.Path.Value); p == path {
+			if imp.Name != nil {
+				return imp.Name.Name
+			}
+			return path[strings.LastIndex(path, "/")+1:]
+		}
+	}
+	return ""
+}
+
+// builtin returns name of predeclared type expr refers to, unless the name
+// is redeclared in the package.
+func (p *pkgInfo) builtin(expr ast.Expr) string {
+	ident, ok := expr.(*ast.Ident)
+	if !ok {
+		return ""
+	}
+	if _, ok := p.types[ident.Name]; ok {
+		return ""
+	}
+	return ident.Name
+}
+
+// generator writes methods of struct types.
+type generator struct {
+	pkg     *pkgInfo
+	body    bytes.Buffer
+	imports map[string]bool
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.body, format, args...)
+}
+
+func (g *generator) use(path string) {
+	if g.imports == nil {
+		g.imports = make(map[string]bool)
+	}
+	g.imports[path] = true
+}
+
+// generateType writes MarshalXMLRPC and UnmarshalXMLRPC methods of decl.
+func (g *generator) generateType(decl *typeDecl) error {
+	fields, err := g.pkg.typeFields(decl)
+	if err != nil {
+		return err
+	}
+
+	g.generateMarshal(decl.name, fields)
+	g.generateUnmarshal(decl.name, fields)
+
+	return nil
+}
+
+func (g *generator) generateMarshal(typeName string, fields []field) {
+	g.printf("\n// MarshalXMLRPC implements xmlrpc.Marshaler.\n")
+	g.printf("func (v %s) MarshalXMLRPC(e *xmlrpc.ValueEncoder) error {\n", typeName)
+	g.printf("e.BeginStruct()\n")
+
+	for i := range fields {
+		f := &fields[i]
+
+		var conds []string
+		for _, gd := range f.guards {
+			conds = append(conds, gd.expr+" != nil")
+		}
+		if f.omitEmpty {
+			conds = append(conds, g.nonZero(f))
+		}
+
+		if len(conds) > 0 {
+			g.printf("if %s {\n", strings.Join(conds, " && "))
+		}
+
+		g.printf("e.BeginMember(%s)\n", strconv.Quote(f.name))
+		if method := g.scalarMethod(f); method != "" {
+			g.printf("e.%s(%s)\n", method, f.selector())
+		} else if wireOptions(f.opts) {
+			g.printf("e.EncodeTagged(&%s, %s)\n", f.selector(), strconv.Quote(f.opts))
+		} else {
+			g.printf("e.Encode(&%s)\n", f.selector())
+		}
+		g.printf("e.EndMember()\n")
+
+		if len(conds) > 0 {
+			g.printf("}\n")
+		}
+	}
+
+	g.printf("e.EndStruct()\n")
+	g.printf("return e.Err()\n")
+	g.printf("}\n")
+}
+
+func (g *generator) generateUnmarshal(typeName string, fields []field) {
+	g.printf("\n// UnmarshalXMLRPC implements xmlrpc.Unmarshaler.\n")
+	g.printf("func (v *%s) UnmarshalXMLRPC(d *xmlrpc.ValueDecoder) error {\n", typeName)
+
+	if len(fields) == 0 {
+		g.printf("return d.Struct(func(name string) (bool, error) {\nreturn false, nil\n})\n}\n")
+		return
+	}
+
+	var required []*field
+	for i := range fields {
+		if fields[i].required {
+			required = append(required, &fields[i])
+		}
+	}
+
+	if len(required) > 0 {
+		g.printf("var seen [%d]bool\n", len(required))
+		g.printf("err := d.Struct(func(name string) (bool, error) {\n")
+	} else {
+		g.printf("return d.Struct(func(name string) (bool, error) {\n")
+	}
+
+	g.printf("var err error\n")
+	g.printf("switch name {\n")
+
+	for i := range fields {
+		f := &fields[i]
+
+		g.printf("case %s:\n", strconv.Quote(f.name))
+		for _, gd := range f.guards {
+			g.printf("if %s == nil {\n%s = new(%s)\n}\n", gd.expr, gd.expr, gd.typeName)
+		}
+
+		if method := g.scalarMethod(f); method != "" {
+			g.printf("%s, err = d.%s()\n", f.selector(), method)
+		} else if wireOptions(f.opts) {
+			g.printf("err = d.DecodeTagged(&%s, %s)\n", f.selector(), strconv.Quote(f.opts))
+		} else {
+			g.printf("err = d.Decode(&%s)\n", f.selector())
+		}
+
+		for j, r := range required {
+			if r == f {
+				g.printf("seen[%d] = true\n", j)
+			}
+		}
+	}
+
+	g.printf("default:\nreturn false, nil\n}\n")
+	g.printf("return true, err\n")
+	g.printf("})\n")
+
+	if len(required) > 0 {
+		g.printf("if err != nil {\nreturn err\n}\n\n")
+		g.printf("var missing []string\n")
+		for j, r := range required {
+			g.printf("if !seen[%d] {\nmissing = append(missing, %s)\n}\n", j, strconv.Quote(r.name))
+		}
+		g.printf("if len(missing) > 0 {\nreturn d.MissingMembers(missing)\n}\n")
+		g.printf("return nil\n")
+	}
+
+	g.printf("}\n")
+}
+
+// scalarMethod returns name of ValueEncoder and ValueDecoder method, which
+// handles type of f without reflection, or empty string if there is none.
+func (g *generator) scalarMethod(f *field) string {
+	if wireOptions(f.opts) {
+		return ""
+	}
+
+	if isTime(f.typ, f.file) {
+		return "Time"
+	}
+
+	switch g.pkg.builtin(f.typ) {
+	case "string":
+		return "String"
+	case "int":
+		return "Int"
+	case "int64":
+		return "Int64"
+	case "float64":
+		return "Float64"
+	case "bool":
+		return "Bool"
+	}
+
+	return ""
+}
+
+// nonZero returns condition reporting that f isn't zero value, the same way
+// reflect.Value.IsZero does.
+func (g *generator) nonZero(f *field) string {
+	sel := f.selector()
+
+	if isTime(f.typ, f.file) {
+		g.use("time")
+		return sel + " != (time.Time{})"
+	}
+
+	switch f.typ.(type) {
+	case *ast.StarExpr, *ast.MapType, *ast.InterfaceType, *ast.FuncType, *ast.ChanType:
+		return sel + " != nil"
+	case *ast.ArrayType:
+		if f.typ.(*ast.ArrayType).Len == nil {
+			return sel + " != nil"
+		}
+	}
+
+	switch g.pkg.builtin(f.typ) {
+	case "string":
+		return sel + ` != ""`
+	case "int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32", "uint64",
+		"uintptr", "byte", "rune", "float32", "float64":
+		return sel + " != 0"
+	case "bool":
+		return sel
+	case "error":
+		return sel + " != nil"
+	}
+
+	g.use("reflect")
+	return "!reflect.ValueOf(" + sel + ").IsZero()"
+}
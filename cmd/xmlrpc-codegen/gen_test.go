@@ -0,0 +1,299 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const typesSrc = `package supervisor
+
+import (
+	stdtime "time"
+)
+
+type State string
+
+type Base struct {
+	ID      int    ` + "`xmlrpc:\"id\"`" + `
+	Comment string ` + "`xmlrpc:\"comment,omitempty\"`" + `
+}
+
+type Extra struct {
+	Note string
+	ID   int ` + "`xmlrpc:\"id\"`" + `
+}
+
+type hidden struct {
+	Secret string ` + "`xmlrpc:\"secret\"`" + `
+}
+
+//xmlrpc:generate
+type ProcessInfo struct {
+	Base
+	*Extra
+	hidden
+	Name     string       ` + "`xmlrpc:\"name,required\"`" + `
+	State    State        ` + "`xmlrpc:\"state,omitempty\"`" + `
+	Pid      int64        ` + "`xmlrpc:\"pid,string\"`" + `
+	Start    stdtime.Time ` + "`xmlrpc:\"start,unix\"`" + `
+	Stop     stdtime.Time ` + "`xmlrpc:\"stop,omitempty\"`" + `
+	Children []Child      ` + "`xmlrpc:\"children,omitempty\"`" + `
+	Skipped  string       ` + "`xmlrpc:\"-\"`" + `
+	internal int
+}
+
+type Child struct {
+	Name string
+}
+`
+
+// paritySrc declares types, whose generated methods are compared with
+// reflection by parityMain.
+const paritySrc = `package main
+
+import "time"
+
+type Base struct {
+	ID      int    ` + "`xmlrpc:\"id\"`" + `
+	Comment string ` + "`xmlrpc:\"comment,omitempty\"`" + `
+}
+
+type Extra struct {
+	Note string
+	ID   int ` + "`xmlrpc:\"id\"`" + `
+}
+
+type hidden struct {
+	Secret string ` + "`xmlrpc:\"secret\"`" + `
+}
+
+type Child struct {
+	Name string
+}
+
+type ProcessInfo struct {
+	Base
+	*Extra
+	hidden
+	Name     string    ` + "`xmlrpc:\"name,required\"`" + `
+	Pid      int64     ` + "`xmlrpc:\"pid,string\"`" + `
+	Start    time.Time ` + "`xmlrpc:\"start,unix\"`" + `
+	Stop     time.Time ` + "`xmlrpc:\"stop,omitempty\"`" + `
+	Children []Child   ` + "`xmlrpc:\"children,omitempty\"`" + `
+	Limits   struct {
+		Max int ` + "`xmlrpc:\"max\"`" + `
+	} ` + "`xmlrpc:\"limits,inline\"`" + `
+	Owner *struct {
+		User string ` + "`xmlrpc:\"user\"`" + `
+	} ` + "`xmlrpc:\",inline\"`" + `
+	Skipped string ` + "`xmlrpc:\"-\"`" + `
+}
+`
+
+// parityMain encodes and decodes values with generated methods and with
+// reflection, and fails if results differ.
+const parityMain = `package main
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"time"
+
+	"github.com/kolo/xmlrpc"
+)
+
+type plainProcessInfo ProcessInfo
+
+func main() {
+	full := ProcessInfo{
+		Base:     Base{ID: 1, Comment: "c"},
+		Extra:    &Extra{Note: "n", ID: 2},
+		hidden:   hidden{Secret: "s"},
+		Name:     "web <1>",
+		Pid:      42,
+		Start:    time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		Stop:     time.Date(2020, 1, 2, 4, 4, 5, 0, time.UTC),
+		Children: []Child{{Name: "a"}, {}},
+	}
+	full.Limits.Max = 3
+	full.Owner = &struct {
+		User string ` + "`xmlrpc:\"user\"`" + `
+	}{User: "root"}
+
+	for _, v := range []ProcessInfo{{}, full} {
+		expected, err := (&xmlrpc.Encoder{}).Marshal(plainProcessInfo(v))
+		if err != nil {
+			fail("reflection error: %v", err)
+		}
+		b, err := (&xmlrpc.Encoder{}).Marshal(v)
+		if err != nil {
+			fail("marshal error: %v", err)
+		}
+		if string(b) != string(expected) {
+			fail("marshal error:\nexpected: %s\n     got: %s", expected, b)
+		}
+
+		var plain plainProcessInfo
+		if err = (&xmlrpc.Decoder{}).Unmarshal(b, &plain); err != nil {
+			fail("reflection error: %v", err)
+		}
+		var decoded ProcessInfo
+		if err = (&xmlrpc.Decoder{}).Unmarshal(b, &decoded); err != nil {
+			fail("unmarshal error: %v", err)
+		}
+		if !reflect.DeepEqual(decoded, ProcessInfo(plain)) {
+			fail("unmarshal error:\nexpected: %+v\n     got: %+v", plain, decoded)
+		}
+	}
+}
+
+func fail(format string, args ...interface{}) {
+	fmt.Fprintf(os.Stderr, format+"\n", args...)
+	os.Exit(1)
+}
+`
+
+// writeSource writes src to a file in a temporary directory and returns its
+// name.
+func writeSource(t *testing.T, src string) string {
+	dir, err := ioutil.TempDir("", "xmlrpc-codegen")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	filename := filepath.Join(dir, "types.go")
+	if err = ioutil.WriteFile(filename, []byte(src), 0644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	return filename
+}
+
+func Test_generate(t *testing.T) {
+	filename := writeSource(t, typesSrc)
+
+	src, err := generate(filename, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	code := string(src)
+	for _, s := range []string{
+		"// Code generated by xmlrpc-codegen. DO NOT EDIT.\n\npackage supervisor\n",
+		"\t\"reflect\"\n\t\"time\"\n\n\t\"github.com/kolo/xmlrpc\"\n",
+		"func (v ProcessInfo) MarshalXMLRPC(e *xmlrpc.ValueEncoder) error {",
+		"if v.Base.Comment != \"\" {\n\t\te.BeginMember(\"comment\")\n\t\te.String(v.Base.Comment)",
+		"if v.Extra != nil {\n\t\te.BeginMember(\"Note\")",
+		"e.BeginMember(\"secret\")\n\te.String(v.hidden.Secret)",
+		"if !reflect.ValueOf(v.State).IsZero() {",
+		"e.EncodeTagged(&v.Pid, \"string\")",
+		"e.EncodeTagged(&v.Start, \"unix\")",
+		"if v.Stop != (time.Time{}) {\n\t\te.BeginMember(\"stop\")\n\t\te.Time(v.Stop)",
+		"if v.Children != nil {\n\t\te.BeginMember(\"children\")\n\t\te.Encode(&v.Children)",
+		"func (v *ProcessInfo) UnmarshalXMLRPC(d *xmlrpc.ValueDecoder) error {",
+		"case \"Note\":\n\t\t\tif v.Extra == nil {\n\t\t\t\tv.Extra = new(Extra)\n\t\t\t}\n\t\t\tv.Extra.Note, err = d.String()",
+		"case \"name\":\n\t\t\tv.Name, err = d.String()\n\t\t\tseen[0] = true",
+		"err = d.DecodeTagged(&v.Pid, \"string\")",
+		"v.Stop, err = d.Time()",
+		"missing = append(missing, \"name\")",
+	} {
+		if !strings.Contains(code, s) {
+			t.Errorf("generated code doesn't contain %q:\n%s", s, code)
+		}
+	}
+
+	// conflicting fields are dropped, ignored and unexported ones skipped
+	for _, s := range []string{`"id"`, "Skipped", "internal", "func (v Child)"} {
+		if strings.Contains(code, s) {
+			t.Errorf("generated code contains %q:\n%s", s, code)
+		}
+	}
+}
+
+func Test_generateAll(t *testing.T) {
+	filename := writeSource(t, typesSrc)
+
+	src, err := generate(filename, true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	code := string(src)
+	for _, s := range []string{
+		"func (v Base) MarshalXMLRPC(e *xmlrpc.ValueEncoder) error {",
+		"func (v *Child) UnmarshalXMLRPC(d *xmlrpc.ValueDecoder) error {\n\treturn d.Struct(",
+	} {
+		if !strings.Contains(code, s) {
+			t.Errorf("generated code doesn't contain %q:\n%s", s, code)
+		}
+	}
+}
+
+func Test_generateErrors(t *testing.T) {
+	filename := writeSource(t, "package p\n\nimport \"net/url\"\n\n//xmlrpc:generate\ntype T struct {\n\turl.URL\n}\n")
+	if _, err := generate(filename, false); err == nil || !strings.Contains(err.Error(), "can't inline URL") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	filename = writeSource(t, "package p\n\ntype T struct{}\n")
+	if src, err := generate(filename, false); err != nil || src != nil {
+		t.Fatalf("unexpected result: %s, %v", src, err)
+	}
+}
+
+func Test_run(t *testing.T) {
+	filename := writeSource(t, typesSrc)
+
+	if err := run(filename, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	out := filepath.Join(filepath.Dir(filename), "types_xmlrpc.go")
+	if _, err := os.Stat(out); err != nil {
+		t.Fatalf("output file isn't written: %v", err)
+	}
+
+	// the generated file is skipped when parsing the package again
+	if err := run(filename, false); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func Test_generateEncodesLikeReflection(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping build of generated code in short mode")
+	}
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go command isn't available")
+	}
+
+	// the package is built inside the module to use its xmlrpc package,
+	// directories starting with "_" are ignored by "./..." patterns.
+	dir, err := ioutil.TempDir(".", "_parity")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	filename := filepath.Join(dir, "types.go")
+	if err = ioutil.WriteFile(filename, []byte(paritySrc), 0644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err = ioutil.WriteFile(filepath.Join(dir, "main.go"), []byte(parityMain), 0644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err = run(filename, true); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	out, err := exec.Command("go", "run", "./"+dir).CombinedOutput()
+	if err != nil {
+		t.Fatalf("generated code differs from reflection: %v\n%s", err, out)
+	}
+}
@@ -0,0 +1,67 @@
+// Command xmlrpc-codegen generates MarshalXMLRPC and UnmarshalXMLRPC methods
+// for Go struct types, so that they are encoded and decoded without
+// reflection. The generated methods follow the same xmlrpc tag rules as the
+// reflection based encoder and decoder.
+//
+// Usage:
+//
+//	xmlrpc-codegen [-all] file.go...
+//
+// Methods are generated for struct types annotated with //xmlrpc:generate
+// comment, or for all struct types declared in the file with -all, and are
+// written to file_xmlrpc.go next to the file:
+//
+//	//xmlrpc:generate
+//	type ProcessInfo struct {
+//		Name  string `xmlrpc:"name"`
+//		State int    `xmlrpc:"state"`
+//	}
+//
+// Embedded and inline structs must be declared in the same package.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+func main() {
+	all := flag.Bool("all", false, "generate methods for all struct types, not only annotated ones")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: xmlrpc-codegen [-all] file.go...\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	for _, filename := range flag.Args() {
+		if err := run(filename, *all); err != nil {
+			fmt.Fprintf(os.Stderr, "xmlrpc-codegen: %v\n", err)
+			os.Exit(1)
+		}
+	}
+}
+
+func run(filename string, all bool) error {
+	src, err := generate(filename, all)
+	if err != nil {
+		return err
+	}
+	if src == nil {
+		return fmt.Errorf("%s: no struct types to generate methods for", filename)
+	}
+
+	return ioutil.WriteFile(outputName(filename), src, 0644)
+}
+
+// outputName returns name of the file generated for filename.
+func outputName(filename string) string {
+	return strings.TrimSuffix(filename, ".go") + "_xmlrpc.go"
+}
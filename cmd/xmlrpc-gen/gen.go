@@ -0,0 +1,305 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/kolo/xmlrpc"
+)
+
+// Description describes methods of an XML-RPC service, as reported by its
+// introspection methods.
+type Description struct {
+	Methods []Method `json:"methods"`
+}
+
+// Method describes a method of an XML-RPC service.
+type Method struct {
+	// Name is the method name, e.g. "system.listMethods".
+	Name string `json:"name"`
+
+	// Signatures lists signatures of the method. The first type of a
+	// signature is the result type, the rest are params types. Empty
+	// signatures mean they are unknown.
+	Signatures [][]string `json:"signatures,omitempty"`
+
+	// Help is the method documentation.
+	Help string `json:"help,omitempty"`
+}
+
+// fetchDescription reads description of service with system.listMethods,
+// system.methodSignature and system.methodHelp methods. Only the first one is
+// required, signatures and help of methods are optional.
+func fetchDescription(client *xmlrpc.Client) (*Description, error) {
+	var names []string
+	if err := client.Call("system.listMethods", nil, &names); err != nil {
+		return nil, fmt.Errorf("system.listMethods: %v", err)
+	}
+	sort.Strings(names)
+
+	desc := &Description{}
+	for _, name := range names {
+		m := Method{Name: name}
+
+		// servers return a string, e.g. "undef", for methods without
+		// signatures
+		var signatures interface{}
+		if err := client.Call("system.methodSignature", name, &signatures); err == nil {
+			m.Signatures = parseSignatures(signatures)
+		}
+
+		var help string
+		if err := client.Call("system.methodHelp", name, &help); err == nil {
+			m.Help = strings.TrimSpace(help)
+		}
+
+		desc.Methods = append(desc.Methods, m)
+	}
+
+	return desc, nil
+}
+
+// parseSignatures converts result of system.methodSignature to signatures.
+func parseSignatures(v interface{}) [][]string {
+	list, ok := v.([]interface{})
+	if !ok {
+		return nil
+	}
+
+	var signatures [][]string
+	for _, item := range list {
+		types, ok := item.([]interface{})
+		if !ok || len(types) == 0 {
+			return nil
+		}
+
+		var signature []string
+		for _, typ := range types {
+			s, ok := typ.(string)
+			if !ok {
+				return nil
+			}
+			signature = append(signature, s)
+		}
+		signatures = append(signatures, signature)
+	}
+
+	return signatures
+}
+
+// readDescription reads description from JSON file.
+func readDescription(filename string) (*Description, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	desc := &Description{}
+	if err := json.Unmarshal(data, desc); err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+	if err := desc.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+
+	return desc, nil
+}
+
+// validate checks that every signature of desc has at least the result type.
+func (desc *Description) validate() error {
+	for _, m := range desc.Methods {
+		for _, signature := range m.Signatures {
+			if len(signature) == 0 {
+				return fmt.Errorf("method %s has empty signature", m.Name)
+			}
+		}
+	}
+	return nil
+}
+
+// writeDescription writes description to JSON file.
+func writeDescription(filename string, desc *Description) error {
+	data, err := json.MarshalIndent(desc, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filename, append(data, '\n'), 0644)
+}
+
+// goTypes maps XML-RPC types to Go types.
+var goTypes = map[string]string{
+	"int":              "int",
+	"i4":               "int",
+	"i8":               "int64",
+	"boolean":          "bool",
+	"string":           "string",
+	"double":           "float64",
+	"dateTime.iso8601": "time.Time",
+	"base64":           "xmlrpc.Base64",
+	"struct":           "map[string]interface{}",
+	"array":            "[]interface{}",
+}
+
+// goType returns Go type for XML-RPC type. Unknown types, e.g. "nil" or
+// "undef", are mapped to interface{}.
+func goType(typ string) string {
+	if t, ok := goTypes[typ]; ok {
+		return t
+	}
+	return "interface{}"
+}
+
+// goName converts method name to exported Go identifier, e.g.
+// "system.listMethods" to "SystemListMethods".
+func goName(method string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range method {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+
+	name := b.String()
+	if name == "" || !unicode.IsLetter([]rune(name)[0]) {
+		name = "M" + name
+	}
+	return name
+}
+
+// stub is a generated client method.
+type stub struct {
+	name   string
+	method string
+	params []string
+	result string
+	help   string
+}
+
+// stubs returns client methods for desc. Methods with several signatures
+// get one client method per signature with a numeric suffix.
+func stubs(desc *Description) []stub {
+	var list []stub
+	used := map[string]bool{"Client": true}
+
+	uniqueName := func(name string) string {
+		unique := name
+		for i := 2; used[unique]; i++ {
+			unique = fmt.Sprintf("%s_%d", name, i)
+		}
+		used[unique] = true
+		return unique
+	}
+
+	for _, m := range desc.Methods {
+		name := goName(m.Name)
+
+		if len(m.Signatures) == 0 {
+			list = append(list, stub{name: uniqueName(name), method: m.Name, result: "interface{}", help: m.Help})
+			continue
+		}
+
+		for i, signature := range m.Signatures {
+			s := stub{name: name, method: m.Name, result: goType(signature[0]), help: m.Help}
+			if len(m.Signatures) > 1 {
+				s.name = fmt.Sprintf("%s%d", name, i+1)
+			}
+			s.name = uniqueName(s.name)
+
+			for _, typ := range signature[1:] {
+				s.params = append(s.params, goType(typ))
+			}
+			list = append(list, s)
+		}
+	}
+
+	return list
+}
+
+// generate returns source of package pkg with client for desc.
+func generate(pkg string, desc *Description) ([]byte, error) {
+	if err := desc.validate(); err != nil {
+		return nil, err
+	}
+
+	list := stubs(desc)
+
+	var usesTime bool
+	for _, s := range list {
+		for _, t := range append([]string{s.result}, s.params...) {
+			if t == "time.Time" {
+				usesTime = true
+			}
+		}
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "// Code generated by xmlrpc-gen. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&b, "package %s\n\n", pkg)
+	fmt.Fprintf(&b, "import (\n\t\"context\"\n")
+	if usesTime {
+		fmt.Fprintf(&b, "\t\"time\"\n")
+	}
+	fmt.Fprintf(&b, "\n\t\"github.com/kolo/xmlrpc\"\n)\n\n")
+
+	fmt.Fprintf(&b, "// Client calls methods of XML-RPC service.\n")
+	fmt.Fprintf(&b, "type Client struct {\n\tclient *xmlrpc.Client\n}\n\n")
+	fmt.Fprintf(&b, "// New returns Client calling methods with client.\n")
+	fmt.Fprintf(&b, "func New(client *xmlrpc.Client) *Client {\n\treturn &Client{client: client}\n}\n")
+
+	for _, s := range list {
+		b.WriteString("\n")
+		writeDoc(&b, s)
+
+		var params, args []string
+		params = append(params, "ctx context.Context")
+		for i, t := range s.params {
+			params = append(params, fmt.Sprintf("arg%d %s", i+1, t))
+			args = append(args, fmt.Sprintf("arg%d", i+1))
+		}
+
+		var callArgs string
+		if len(args) == 0 {
+			callArgs = "nil"
+		} else {
+			callArgs = fmt.Sprintf("[]interface{}{%s}", strings.Join(args, ", "))
+		}
+
+		fmt.Fprintf(&b, "func (c *Client) %s(%s) (%s, error) {\n", s.name, strings.Join(params, ", "), s.result)
+		fmt.Fprintf(&b, "\tvar reply %s\n", s.result)
+		fmt.Fprintf(&b, "\terr := c.client.CallContext(ctx, %q, %s, &reply)\n", s.method, callArgs)
+		fmt.Fprintf(&b, "\treturn reply, err\n}\n")
+	}
+
+	return format.Source(b.Bytes())
+}
+
+// writeDoc writes doc comment of stub, made of its help.
+func writeDoc(b *bytes.Buffer, s stub) {
+	fmt.Fprintf(b, "// %s calls %s.\n", s.name, s.method)
+	if s.help == "" {
+		return
+	}
+
+	b.WriteString("//\n")
+	for _, line := range strings.Split(s.help, "\n") {
+		line = strings.TrimRightFunc(line, unicode.IsSpace)
+		if line == "" {
+			b.WriteString("//\n")
+		} else {
+			fmt.Fprintf(b, "// %s\n", line)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kolo/xmlrpc"
+)
+
+func newIntrospectionServer() *xmlrpc.Server {
+	signatures := map[string]interface{}{
+		"math.sum":       []interface{}{[]string{"int", "int", "int"}, []string{"double", "double", "double"}},
+		"system.version": []interface{}{[]string{"string"}},
+		"log.write":      "undef",
+	}
+	help := map[string]string{
+		"math.sum":       "Adds two numbers.\n\nReturns their sum.",
+		"system.version": "Returns version.",
+	}
+
+	s := xmlrpc.NewServer()
+	s.Handle("system.listMethods", func(ctx context.Context, call *xmlrpc.Call) (interface{}, error) {
+		return []string{"system.version", "math.sum", "log.write"}, nil
+	})
+	s.Handle("system.methodSignature", func(ctx context.Context, call *xmlrpc.Call) (interface{}, error) {
+		var name string
+		if err := call.Decode(&name); err != nil {
+			return nil, err
+		}
+		return signatures[name], nil
+	})
+	s.Handle("system.methodHelp", func(ctx context.Context, call *xmlrpc.Call) (interface{}, error) {
+		var name string
+		if err := call.Decode(&name); err != nil {
+			return nil, err
+		}
+		return help[name], nil
+	})
+
+	return s
+}
+
+func Test_fetchDescription(t *testing.T) {
+	ts := httptest.NewServer(newIntrospectionServer())
+	defer ts.Close()
+
+	client, err := xmlrpc.NewClient(ts.URL, nil)
+	if err != nil {
+		t.Fatalf("Can't create client: %v", err)
+	}
+	defer client.Close()
+
+	desc, err := fetchDescription(client)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := &Description{Methods: []Method{
+		{Name: "log.write"},
+		{Name: "math.sum", Signatures: [][]string{{"int", "int", "int"}, {"double", "double", "double"}}, Help: "Adds two numbers.\n\nReturns their sum."},
+		{Name: "system.version", Signatures: [][]string{{"string"}}, Help: "Returns version."},
+	}}
+	if !reflect.DeepEqual(desc, expected) {
+		t.Fatalf("unexpected description:\nexpected: %+v\n     got: %+v", expected, desc)
+	}
+
+	// recorded description is read back the same
+	dir, err := ioutil.TempDir("", "xmlrpc-gen")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "desc.json")
+	if err := writeDescription(filename, desc); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	recorded, err := readDescription(filename)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(recorded, expected) {
+		t.Fatalf("unexpected recorded description: %+v", recorded)
+	}
+}
+
+func Test_generate(t *testing.T) {
+	desc := &Description{Methods: []Method{
+		{Name: "log.write"},
+		{Name: "math.sum", Signatures: [][]string{{"int", "int", "int"}, {"double", "double", "double"}}, Help: "Adds two numbers.\n\nReturns their sum."},
+		{Name: "system.time", Signatures: [][]string{{"dateTime.iso8601"}}},
+		{Name: "blob.put", Signatures: [][]string{{"boolean", "base64", "struct", "array", "i8", "nil"}}},
+		{Name: "client", Signatures: [][]string{{"string"}}},
+		{Name: "math_sum1"},
+	}}
+
+	src, err := generate("service", desc)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	typeCheck(t, src)
+
+	code := string(src)
+	for _, s := range []string{
+		"package service",
+		"\t\"time\"\n",
+		"func (c *Client) LogWrite(ctx context.Context) (interface{}, error) {",
+		"// MathSum1 calls math.sum.\n//\n// Adds two numbers.\n//\n// Returns their sum.\nfunc (c *Client) MathSum1(ctx context.Context, arg1 int, arg2 int) (int, error) {",
+		"func (c *Client) MathSum2(ctx context.Context, arg1 float64, arg2 float64) (float64, error) {",
+		`err := c.client.CallContext(ctx, "math.sum", []interface{}{arg1, arg2}, &reply)`,
+		"func (c *Client) SystemTime(ctx context.Context) (time.Time, error) {",
+	} {
+		if !strings.Contains(code, s) {
+			t.Errorf("generated code doesn't contain %q:\n%s", s, code)
+		}
+	}
+}
+
+// typeCheck fails the test if generated package src doesn't compile.
+func typeCheck(t *testing.T, src []byte) {
+	t.Helper()
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, "client.go", src, 0)
+	if err != nil {
+		t.Fatalf("generated code doesn't parse: %v\n%s", err, src)
+	}
+
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	if _, err = conf.Check("service", fset, []*ast.File{f}, nil); err != nil {
+		t.Fatalf("generated code doesn't compile: %v\n%s", err, src)
+	}
+}
+
+func Test_generateEmptySignature(t *testing.T) {
+	desc := &Description{Methods: []Method{{Name: "math.sum", Signatures: [][]string{{}}}}}
+	if _, err := generate("service", desc); err == nil || !strings.Contains(err.Error(), "math.sum has empty signature") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	dir, err := ioutil.TempDir("", "xmlrpc-gen")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "desc.json")
+	if err = ioutil.WriteFile(filename, []byte(`{"methods": [{"name": "math.sum", "signatures": [[]]}]}`), 0644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err = readDescription(filename); err == nil || !strings.Contains(err.Error(), "empty signature") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func Test_goName(t *testing.T) {
+	tests := map[string]string{
+		"system.listMethods":        "SystemListMethods",
+		"supervisor.getState":       "SupervisorGetState",
+		"wp_getPosts":               "WpGetPosts",
+		"2fa.check":                 "M2faCheck",
+		"metaWeblog.newMediaObject": "MetaWeblogNewMediaObject",
+	}
+
+	for method, expected := range tests {
+		if got := goName(method); got != expected {
+			t.Errorf("goName(%q): expected %q, got %q", method, expected, got)
+		}
+	}
+}
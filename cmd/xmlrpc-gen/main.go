@@ -0,0 +1,74 @@
+// Command xmlrpc-gen generates typed Go client of an XML-RPC service from
+// its introspection methods: system.listMethods, system.methodSignature and
+// system.methodHelp.
+//
+// Usage:
+//
+//	xmlrpc-gen -url http://localhost/RPC2 -pkg supervisor -o client.go
+//	xmlrpc-gen -url http://localhost/RPC2 -record supervisor.json
+//	xmlrpc-gen -desc supervisor.json -pkg supervisor -o client.go
+//
+// Description of a service can be recorded into a JSON file with -record
+// and used later with -desc instead of a live service.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/kolo/xmlrpc"
+)
+
+func main() {
+	url := flag.String("url", "", "URL of XML-RPC service")
+	descFile := flag.String("desc", "", "JSON description of XML-RPC service, used instead of -url")
+	record := flag.String("record", "", "write description of service at -url to JSON file")
+	pkg := flag.String("pkg", "client", "package name of generated code")
+	out := flag.String("o", "", "output file, standard output if empty")
+	flag.Parse()
+
+	if err := run(*url, *descFile, *record, *pkg, *out); err != nil {
+		fmt.Fprintf(os.Stderr, "xmlrpc-gen: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(url, descFile, record, pkg, out string) error {
+	var desc *Description
+	var err error
+
+	switch {
+	case descFile != "":
+		desc, err = readDescription(descFile)
+	case url != "":
+		var client *xmlrpc.Client
+		if client, err = xmlrpc.NewClient(url, nil); err != nil {
+			return err
+		}
+		defer client.Close()
+
+		desc, err = fetchDescription(client)
+	default:
+		return fmt.Errorf("either -url or -desc must be set")
+	}
+	if err != nil {
+		return err
+	}
+
+	if record != "" {
+		return writeDescription(record, desc)
+	}
+
+	src, err := generate(pkg, desc)
+	if err != nil {
+		return err
+	}
+
+	if out == "" {
+		_, err = os.Stdout.Write(src)
+		return err
+	}
+	return ioutil.WriteFile(out, src, 0644)
+}
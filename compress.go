@@ -0,0 +1,89 @@
+package xmlrpc
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// acceptEncoding is the value of Accept-Encoding header listing content
+// encodings supported by decompressBody.
+const acceptEncoding = "gzip, deflate"
+
+// compressBody compresses data with content encoding, which is "gzip" or
+// "deflate".
+func compressBody(data []byte, encoding string) ([]byte, error) {
+	var b bytes.Buffer
+	var w io.WriteCloser
+
+	switch strings.ToLower(encoding) {
+	case "gzip":
+		w = gzip.NewWriter(&b)
+	case "deflate":
+		w = zlib.NewWriter(&b)
+	default:
+		return nil, fmt.Errorf("unsupported content encoding %q", encoding)
+	}
+
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return b.Bytes(), nil
+}
+
+// decompressBody returns reader that decompresses body compressed with
+// content encoding given by Content-Encoding header. Limits of Decoder are
+// applied to the returned reader, so they restrict size of decompressed data.
+func decompressBody(body io.Reader, encoding string) (io.Reader, error) {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "", "identity":
+		return body, nil
+	case "gzip", "x-gzip":
+		return gzip.NewReader(body)
+	case "deflate":
+		// deflate must be zlib stream, but some servers send raw deflate
+		br := bufio.NewReader(body)
+		if head, _ := br.Peek(2); isZlibHeader(head) {
+			return zlib.NewReader(br)
+		}
+		return flate.NewReader(br), nil
+	default:
+		return nil, fmt.Errorf("unsupported content encoding %q", encoding)
+	}
+}
+
+// isZlibHeader reports whether head starts with a valid zlib header.
+func isZlibHeader(head []byte) bool {
+	return len(head) == 2 && head[0]&0x0f == 8 && (uint16(head[0])<<8|uint16(head[1]))%31 == 0
+}
+
+// acceptsEncoding reports whether Accept-Encoding header value accept lists
+// content encoding.
+func acceptsEncoding(accept string, encoding string) bool {
+	for _, s := range strings.Split(accept, ",") {
+		name := s
+		if i := strings.Index(s, ";"); i >= 0 {
+			name = s[:i]
+			param := strings.TrimSpace(s[i+1:])
+			if strings.HasPrefix(param, "q=") {
+				if q, err := strconv.ParseFloat(param[2:], 64); err == nil && q == 0 {
+					continue
+				}
+			}
+		}
+		if strings.EqualFold(strings.TrimSpace(name), encoding) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,170 @@
+package xmlrpc
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_compressBody(t *testing.T) {
+	data := []byte(strings.Repeat("<value><string>War and Piece</string></value>", 100))
+
+	for _, encoding := range []string{"gzip", "deflate"} {
+		compressed, err := compressBody(data, encoding)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", encoding, err)
+		}
+		if len(compressed) >= len(data) {
+			t.Fatalf("%s: body isn't compressed", encoding)
+		}
+
+		r, err := decompressBody(bytes.NewReader(compressed), encoding)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", encoding, err)
+		}
+		decompressed, err := ioutil.ReadAll(r)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", encoding, err)
+		}
+		if !bytes.Equal(decompressed, data) {
+			t.Fatalf("%s: decompressed body doesn't match", encoding)
+		}
+	}
+
+	if _, err := compressBody(data, "br"); err == nil {
+		t.Fatal("expected error for unsupported encoding, but didn't get it")
+	}
+	if _, err := decompressBody(bytes.NewReader(data), "br"); err == nil {
+		t.Fatal("expected error for unsupported encoding, but didn't get it")
+	}
+}
+
+func Test_decompressRawDeflate(t *testing.T) {
+	var b bytes.Buffer
+	w, _ := flate.NewWriter(&b, flate.DefaultCompression)
+	w.Write([]byte(booksRespXML))
+	w.Close()
+
+	r, err := decompressBody(&b, "deflate")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(data) != booksRespXML {
+		t.Fatal("decompressed body doesn't match")
+	}
+}
+
+func Test_acceptsEncoding(t *testing.T) {
+	tests := []struct {
+		accept   string
+		encoding string
+		expected bool
+	}{
+		{"", "gzip", false},
+		{"gzip", "gzip", true},
+		{"deflate, GZIP", "gzip", true},
+		{"deflate;q=0.5, gzip;q=1.0", "deflate", true},
+		{"gzip;q=0, deflate", "gzip", false},
+		{"br", "gzip", false},
+	}
+
+	for _, tt := range tests {
+		if got := acceptsEncoding(tt.accept, tt.encoding); got != tt.expected {
+			t.Errorf("acceptsEncoding(%q, %q): expected %v, got %v", tt.accept, tt.encoding, tt.expected, got)
+		}
+	}
+}
+
+func Test_newRequestContentEncoding(t *testing.T) {
+	req, err := (&Encoder{ContentEncoding: "gzip"}).NewRequest("http://localhost", "library.add", "War and Piece")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ce := req.Header.Get("Content-Encoding"); ce != "gzip" {
+		t.Fatalf("unexpected Content-Encoding: %q", ce)
+	}
+
+	zr, err := gzip.NewReader(req.Body)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	body, err := ioutil.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected, _ := EncodeMethodCall("library.add", "War and Piece")
+	if !bytes.Equal(body, expected) {
+		t.Fatalf("unexpected body: %s", body)
+	}
+}
+
+func Test_clientCompressedResponse(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ae := r.Header.Get("Accept-Encoding"); ae != acceptEncoding {
+			t.Errorf("unexpected Accept-Encoding: %q", ae)
+		}
+
+		body, _ := compressBody([]byte(booksRespXML), "gzip")
+		w.Header().Set("Content-Encoding", "gzip")
+		w.Write(body)
+	}))
+	defer ts.Close()
+
+	client, err := NewClient(ts.URL, nil)
+	if err != nil {
+		t.Fatalf("Can't create client: %v", err)
+	}
+	defer client.Close()
+
+	var books []book
+	if err := client.Call("export.books", nil, &books); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(books) != 3 {
+		t.Fatalf("expected 3 books, got %d", len(books))
+	}
+
+	// limit applies to decompressed body
+	client, err = NewClientWithOptions(ts.URL, nil, &ClientOptions{Decoder: &Decoder{Limits: Limits{MaxBodySize: 300}}})
+	if err != nil {
+		t.Fatalf("Can't create client: %v", err)
+	}
+	defer client.Close()
+
+	if err := client.Call("export.books", nil, &books); err == nil {
+		t.Fatal("expected limit error, but didn't get it")
+	}
+}
+
+func Test_clientDisableCompression(t *testing.T) {
+	var accept string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		accept = r.Header.Get("Accept-Encoding")
+		w.Write([]byte(booksRespXML))
+	}))
+	defer ts.Close()
+
+	client, err := NewClientWithOptions(ts.URL, nil, &ClientOptions{DisableCompression: true})
+	if err != nil {
+		t.Fatalf("Can't create client: %v", err)
+	}
+	defer client.Close()
+
+	var books []book
+	if err := client.Call("export.books", nil, &books); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if accept != "identity" {
+		t.Fatalf("unexpected Accept-Encoding: %q", accept)
+	}
+}
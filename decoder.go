@@ -2,10 +2,14 @@ package xmlrpc
 
 import (
 	"bytes"
+	"encoding"
+	"encoding/base64"
 	"encoding/xml"
 	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"math"
 	"reflect"
 	"strconv"
 	"strings"
@@ -13,18 +17,21 @@ import (
 )
 
 const (
-	iso8601        = "20060102T15:04:05"
-	iso8601Z       = "20060102T15:04:05Z07:00"
-	iso8601Hyphen  = "2006-01-02T15:04:05"
-	iso8601HyphenZ = "2006-01-02T15:04:05Z07:00"
+	iso8601           = "20060102T15:04:05"
+	iso8601Z          = "20060102T15:04:05Z07:00"
+	iso8601Hyphen     = "2006-01-02T15:04:05"
+	iso8601HyphenZ    = "2006-01-02T15:04:05Z07:00"
+	iso8601Date       = "20060102"
+	iso8601HyphenDate = "2006-01-02"
 )
 
 var (
 	// CharsetReader is a function to generate reader which converts a non UTF-8
-	// charset into UTF-8.
+	// charset into UTF-8. If nil, charsets registered by IANA are converted
+	// with golang.org/x/text encodings.
 	CharsetReader func(string, io.Reader) (io.Reader, error)
 
-	timeLayouts     = []string{iso8601, iso8601Z, iso8601Hyphen, iso8601HyphenZ}
+	timeLayouts     = []string{iso8601, iso8601Z, iso8601Hyphen, iso8601HyphenZ, iso8601Date, iso8601HyphenDate}
 	invalidXmlError = errors.New("invalid xml")
 )
 
@@ -32,17 +39,224 @@ type TypeMismatchError string
 
 func (e TypeMismatchError) Error() string { return string(e) }
 
+// UnknownMembersError is returned when a Decoder with DisallowUnknownMembers set
+// decodes a struct value whose members have no matching field in the Go struct.
+type UnknownMembersError struct {
+	Type    reflect.Type
+	Members []string
+}
+
+func (e UnknownMembersError) Error() string {
+	return fmt.Sprintf("error: unknown members for %v: %s", e.Type, strings.Join(e.Members, ", "))
+}
+
+// MissingMembersError is returned when a struct value lacks members for fields
+// tagged with the "required" option.
+type MissingMembersError struct {
+	Type    reflect.Type
+	Members []string
+}
+
+func (e MissingMembersError) Error() string {
+	return fmt.Sprintf("error: missing required members for %v: %s", e.Type, strings.Join(e.Members, ", "))
+}
+
+// LimitError is returned when decoded input exceeds one of the Limits.
+type LimitError struct {
+	// Limit is the name of the exceeded Limits field.
+	Limit string
+	// Max is the configured maximum.
+	Max int64
+}
+
+func (e LimitError) Error() string {
+	return fmt.Sprintf("error: input exceeds %s limit of %d", e.Limit, e.Max)
+}
+
+// Limits restricts resources spent on decoding of untrusted input. Zero value
+// of a field means there is no limit.
+type Limits struct {
+	// MaxBodySize is the maximum size of a response or request body in bytes.
+	MaxBodySize int64
+
+	// MaxDepth is the maximum nesting depth of values.
+	MaxDepth int
+
+	// MaxArrayLength is the maximum number of values in an array.
+	MaxArrayLength int
+
+	// MaxStructMembers is the maximum number of members in a struct.
+	MaxStructMembers int
+
+	// MaxStringLength is the maximum length of string and base64 values in bytes.
+	MaxStringLength int
+}
+
+// DuplicateMemberError is returned when a Decoder with DuplicateMembers set to
+// DuplicateError decodes a struct value with repeated member names.
+type DuplicateMemberError struct {
+	Type reflect.Type
+	Name string
+}
+
+func (e DuplicateMemberError) Error() string {
+	return fmt.Sprintf("error: duplicate member %q for %v", e.Name, e.Type)
+}
+
+// DuplicatePolicy defines how struct values with repeated member names are
+// decoded.
+type DuplicatePolicy int
+
+const (
+	// DuplicateLastWins keeps value of the last member with the same name.
+	DuplicateLastWins DuplicatePolicy = iota
+
+	// DuplicateFirstWins keeps value of the first member with the same name.
+	DuplicateFirstWins
+
+	// DuplicateError makes decoding fail with DuplicateMemberError.
+	DuplicateError
+)
+
+// Decoder holds options that control decoding of XML-RPC values into Go values.
+// The zero value decodes values the same way Response.Unmarshal does.
+type Decoder struct {
+	// DisallowUnknownMembers makes decoding into a Go struct fail with
+	// UnknownMembersError when a struct value has members that don't match
+	// any of its fields.
+	DisallowUnknownMembers bool
+
+	// CoerceTypes allows to decode values into Go types that don't match their
+	// XML-RPC type: int and double are converted to each other, string is
+	// parsed as a number, boolean or time, and numbers are decoded into
+	// strings. Conversions that would lose data still fail.
+	CoerceTypes bool
+
+	// TimeLayouts are layouts tried, in addition to the built-in ones, to
+	// parse dateTime.iso8601 values. Fractional seconds are accepted by
+	// any layout.
+	TimeLayouts []string
+
+	// TimeLocation is the location of dateTime.iso8601 values without time
+	// zone offset. If nil, such values are in UTC.
+	TimeLocation *time.Location
+
+	// DuplicateMembers is the policy for struct values with repeated member
+	// names. By default the last member wins.
+	DuplicateMembers DuplicatePolicy
+
+	// Charset, if set, is the charset of HTTP bodies read by Client. It takes
+	// precedence over charset given by BOM, Content-Type header and XML
+	// declaration, which is useful for servers that mislabel their payloads.
+	Charset string
+
+	// Limits restricts size of decoded input. They are applied to both
+	// ReadBody and Unmarshal, so the same Decoder can guard client responses
+	// and requests parsed by a server.
+	Limits Limits
+}
+
+// ReadBody reads a request or response body from r, failing with LimitError
+// if it is larger than Limits.MaxBodySize.
+func (d *Decoder) ReadBody(r io.Reader) ([]byte, error) {
+	return ioutil.ReadAll(d.limitBody(r))
+}
+
+// limitBody wraps r to fail with LimitError after reading more than
+// Limits.MaxBodySize bytes.
+func (d *Decoder) limitBody(r io.Reader) io.Reader {
+	if d.Limits.MaxBodySize <= 0 {
+		return r
+	}
+	return &bodyLimitReader{r: r, max: d.Limits.MaxBodySize}
+}
+
+type bodyLimitReader struct {
+	r   io.Reader
+	n   int64
+	max int64
+}
+
+func (l *bodyLimitReader) Read(p []byte) (int, error) {
+	if l.n >= l.max {
+		// the limit is reached, fail if there is anything left to read
+		var b [1]byte
+		n, err := l.r.Read(b[:])
+		if n > 0 {
+			return 0, LimitError{Limit: "MaxBodySize", Max: l.max}
+		}
+		return 0, err
+	}
+
+	if int64(len(p)) > l.max-l.n {
+		p = p[:l.max-l.n]
+	}
+
+	n, err := l.r.Read(p)
+	l.n += int64(n)
+
+	return n, err
+}
+
+// Unmarshal decodes the first value found in data, which is usually a
+// Response, into the value pointed to by v.
+func (d *Decoder) Unmarshal(data []byte, v interface{}) error {
+	if max := d.Limits.MaxBodySize; max > 0 && int64(len(data)) > max {
+		return LimitError{Limit: "MaxBodySize", Max: max}
+	}
+
+	return d.unmarshal(data, v)
+}
+
 type decoder struct {
 	*xml.Decoder
+	opts *Decoder
+
+	// depth is the nesting depth of the value being decoded.
+	depth int
+
+	// valueClosed reports that decodeValue has consumed the </value> end
+	// element, which happens for empty values.
+	valueClosed bool
+}
+
+// skipValueEnd consumes the </value> end element left after decodeValue.
+func (dec *decoder) skipValueEnd() error {
+	if dec.valueClosed {
+		dec.valueClosed = false
+		return nil
+	}
+	return dec.Skip()
 }
 
-func unmarshal(data []byte, v interface{}) (err error) {
-	dec := &decoder{xml.NewDecoder(bytes.NewBuffer(data))}
+// checkLimit returns LimitError if n exceeds max limit named name. Zero max
+// means there is no limit.
+func checkLimit(name string, n int, max int) error {
+	if max > 0 && n > max {
+		return LimitError{Limit: name, Max: int64(max)}
+	}
+	return nil
+}
+
+func (d *Decoder) newDecoder(r io.Reader) *decoder {
+	dec := &decoder{Decoder: xml.NewDecoder(r), opts: d}
 
 	if CharsetReader != nil {
 		dec.CharsetReader = CharsetReader
+	} else {
+		dec.CharsetReader = charsetReader
 	}
 
+	return dec
+}
+
+func unmarshal(data []byte, v interface{}) error {
+	return new(Decoder).unmarshal(data, v)
+}
+
+func (d *Decoder) unmarshal(data []byte, v interface{}) (err error) {
+	dec := d.newDecoder(bytes.NewBuffer(data))
+
 	var tok xml.Token
 	for {
 		if tok, err = dec.Token(); err != nil {
@@ -55,7 +269,7 @@ func unmarshal(data []byte, v interface{}) (err error) {
 				if val.Kind() != reflect.Ptr {
 					return errors.New("non-pointer value passed to unmarshal")
 				}
-				if err = dec.decodeValue(val.Elem()); err != nil {
+				if err = dec.decodeValue(val.Elem(), ""); err != nil {
 					return err
 				}
 
@@ -73,10 +287,19 @@ func unmarshal(data []byte, v interface{}) (err error) {
 	return nil
 }
 
-func (dec *decoder) decodeValue(val reflect.Value) error {
+// decodeValue decodes a value, which <value> start element has been consumed,
+// into val. opts holds tag options of the struct field val belongs to, if any.
+func (dec *decoder) decodeValue(val reflect.Value, opts tagOptions) error {
 	var tok xml.Token
 	var err error
 
+	dec.depth++
+	defer func() { dec.depth-- }()
+
+	if err = checkLimit("MaxDepth", dec.depth, dec.opts.Limits.MaxDepth); err != nil {
+		return err
+	}
+
 	if val.Kind() == reflect.Ptr {
 		if val.IsNil() {
 			val.Set(reflect.New(val.Type().Elem()))
@@ -84,6 +307,10 @@ func (dec *decoder) decodeValue(val reflect.Value) error {
 		val = val.Elem()
 	}
 
+	if u, ok := unmarshaler(val); ok {
+		return u.UnmarshalXMLRPC(&ValueDecoder{dec: dec, typ: val.Type()})
+	}
+
 	var typeName string
 	for {
 		if tok, err = dec.Token(); err != nil {
@@ -92,6 +319,7 @@ func (dec *decoder) decodeValue(val reflect.Value) error {
 
 		if t, ok := tok.(xml.EndElement); ok {
 			if t.Name.Local == "value" {
+				dec.valueClosed = true
 				return nil
 			} else {
 				return invalidXmlError
@@ -106,6 +334,24 @@ func (dec *decoder) decodeValue(val reflect.Value) error {
 		// Treat value data without type identifier as string
 		if t, ok := tok.(xml.CharData); ok {
 			if value := strings.TrimSpace(string(t)); value != "" {
+				if err = checkLimit("MaxStringLength", len(value), dec.opts.Limits.MaxStringLength); err != nil {
+					return err
+				}
+
+				if ok, err := dec.decodeTagged(val, "string", value, opts); ok {
+					return err
+				}
+
+				if u, ok := textUnmarshaler(val); ok {
+					return u.UnmarshalText([]byte(value))
+				}
+
+				if dec.opts.CoerceTypes {
+					if ok, err := dec.coerceValue(val, "string", value, opts); ok {
+						return err
+					}
+				}
+
 				if err = checkType(val, reflect.String); err != nil {
 					return err
 				}
@@ -121,11 +367,14 @@ func (dec *decoder) decodeValue(val reflect.Value) error {
 		ismap := false
 		pmap := val
 		valType := val.Type()
+		ordered := valType == orderedStructType
 
-		if err = checkType(val, reflect.Struct); err != nil {
+		if ordered {
+			val.Set(reflect.MakeSlice(valType, 0, 0))
+		} else if err = checkType(val, reflect.Struct); err != nil {
 			if checkType(val, reflect.Map) == nil {
-				if valType.Key().Kind() != reflect.String {
-					return fmt.Errorf("only maps with string key type can be unmarshalled")
+				if key := valType.Key(); key.Kind() != reflect.String && !reflect.PtrTo(key).Implements(textUnmarshalerType) {
+					return fmt.Errorf("only maps with string key type or key type implementing encoding.TextUnmarshaler can be unmarshalled")
 				}
 				ismap = true
 			} else if checkType(val, reflect.Interface) == nil && val.IsNil() {
@@ -139,26 +388,25 @@ func (dec *decoder) decodeValue(val reflect.Value) error {
 			}
 		}
 
-		var fields map[string]reflect.Value
+		var fields *structFields
+		var unknown []string
 
-		if !ismap {
-			fields = make(map[string]reflect.Value)
+		// seen holds names of processed members. It's required to find
+		// missing and duplicate members.
+		var seen map[string]bool
+		if dec.opts.DuplicateMembers != DuplicateLastWins {
+			seen = make(map[string]bool)
+		}
 
-			for i := 0; i < valType.NumField(); i++ {
-				field := valType.Field(i)
-				fieldVal := val.FieldByName(field.Name)
+		// positions holds indexes of members of OrderedStruct.
+		var positions map[string]int
 
-				if fieldVal.CanSet() {
-					name := field.Tag.Get("xmlrpc")
-					name = strings.TrimSuffix(name, ",omitempty")
-					if name == "-" {
-						continue
-					}
-					if name == "" {
-						name = field.Name
-					}
-					fields[name] = fieldVal
-				}
+		if ordered {
+			positions = make(map[string]int)
+		} else if !ismap {
+			fields = cachedTypeFields(valType)
+			if fields.hasRequired && seen == nil {
+				seen = make(map[string]bool)
 			}
 		} else {
 			// Create initial empty map
@@ -166,6 +414,7 @@ func (dec *decoder) decodeValue(val reflect.Value) error {
 		}
 
 		// Process struct members.
+		var members int
 	StructLoop:
 		for {
 			if tok, err = dec.Token(); err != nil {
@@ -177,6 +426,11 @@ func (dec *decoder) decodeValue(val reflect.Value) error {
 					return invalidXmlError
 				}
 
+				members++
+				if err = checkLimit("MaxStructMembers", members, dec.opts.Limits.MaxStructMembers); err != nil {
+					return err
+				}
+
 				tagName, fieldName, err := dec.readTag()
 				if err != nil {
 					return err
@@ -186,11 +440,35 @@ func (dec *decoder) decodeValue(val reflect.Value) error {
 				}
 
 				var fv reflect.Value
+				var fopts tagOptions
 				ok := true
 
-				if !ismap {
-					fv, ok = fields[string(fieldName)]
-				} else {
+				if seen[string(fieldName)] {
+					switch dec.opts.DuplicateMembers {
+					case DuplicateError:
+						return DuplicateMemberError{Type: valType, Name: string(fieldName)}
+					case DuplicateFirstWins:
+						ok = false
+					}
+				}
+				if seen != nil {
+					seen[string(fieldName)] = true
+				}
+
+				switch {
+				case !ok:
+					// duplicate member is skipped
+				case ordered:
+					fv = reflect.New(emptyInterfaceType)
+				case !ismap:
+					var f *field
+					if f, ok = fields.byName[string(fieldName)]; ok {
+						fv = fieldByIndexAlloc(val, f.index)
+						fopts = f.opts
+					} else if dec.opts.DisallowUnknownMembers {
+						unknown = append(unknown, string(fieldName))
+					}
+				default:
 					fv = reflect.New(valType.Elem())
 				}
 
@@ -200,12 +478,12 @@ func (dec *decoder) decodeValue(val reflect.Value) error {
 							return err
 						}
 						if t, ok := tok.(xml.StartElement); ok && t.Name.Local == "value" {
-							if err = dec.decodeValue(fv); err != nil {
+							if err = dec.decodeValue(fv, fopts); err != nil {
 								return err
 							}
 
 							// </value>
-							if err = dec.Skip(); err != nil {
+							if err = dec.skipValueEnd(); err != nil {
 								return err
 							}
 
@@ -219,19 +497,49 @@ func (dec *decoder) decodeValue(val reflect.Value) error {
 					return err
 				}
 
-				if ismap {
-					pmap.SetMapIndex(reflect.ValueOf(string(fieldName)), reflect.Indirect(fv))
+				if ok && ordered {
+					m := reflect.ValueOf(Member{Name: string(fieldName), Value: fv.Elem().Interface()})
+					if i, dup := positions[string(fieldName)]; dup {
+						val.Index(i).Set(m)
+					} else {
+						positions[string(fieldName)] = val.Len()
+						val.Set(reflect.Append(val, m))
+					}
+				}
+
+				if ok && ismap {
+					key, err := mapKey(valType.Key(), fieldName)
+					if err != nil {
+						return err
+					}
+					pmap.SetMapIndex(key, reflect.Indirect(fv))
 					val.Set(pmap)
 				}
 			case xml.EndElement:
 				break StructLoop
 			}
 		}
+
+		if len(unknown) > 0 {
+			return UnknownMembersError{Type: valType, Members: unknown}
+		}
+
+		var missing []string
+		if fields != nil && fields.hasRequired {
+			for _, f := range fields.list {
+				if f.required && !seen[f.name] {
+					missing = append(missing, f.name)
+				}
+			}
+		}
+		if len(missing) > 0 {
+			return MissingMembersError{Type: valType, Members: missing}
+		}
 	case "array":
 		slice := val
 		if checkType(val, reflect.Interface) == nil && val.IsNil() {
 			slice = reflect.ValueOf([]interface{}{})
-		} else if err = checkType(val, reflect.Slice); err != nil {
+		} else if err = checkType(val, reflect.Slice, reflect.Array); err != nil {
 			return err
 		}
 
@@ -259,7 +567,18 @@ func (dec *decoder) decodeValue(val reflect.Value) error {
 							return invalidXmlError
 						}
 
-						if index < slice.Len() {
+						if err = checkLimit("MaxArrayLength", index+1, dec.opts.Limits.MaxArrayLength); err != nil {
+							return err
+						}
+
+						if slice.Kind() == reflect.Array {
+							if index >= slice.Len() {
+								return arrayLengthError(slice.Type(), fmt.Sprintf("array of more than %d values", slice.Len()))
+							}
+							if err = dec.decodeValue(slice.Index(index), ""); err != nil {
+								return err
+							}
+						} else if index < slice.Len() {
 							v := slice.Index(index)
 							if v.Kind() == reflect.Interface {
 								v = v.Elem()
@@ -267,23 +586,26 @@ func (dec *decoder) decodeValue(val reflect.Value) error {
 							if v.Kind() != reflect.Ptr {
 								return errors.New("error: cannot write to non-pointer array element")
 							}
-							if err = dec.decodeValue(v); err != nil {
+							if err = dec.decodeValue(v, ""); err != nil {
 								return err
 							}
 						} else {
 							v := reflect.New(slice.Type().Elem())
-							if err = dec.decodeValue(v); err != nil {
+							if err = dec.decodeValue(v, ""); err != nil {
 								return err
 							}
 							slice = reflect.Append(slice, v.Elem())
 						}
 
 						// </value>
-						if err = dec.Skip(); err != nil {
+						if err = dec.skipValueEnd(); err != nil {
 							return err
 						}
 						index++
 					case xml.EndElement:
+						if slice.Kind() == reflect.Array && index != slice.Len() {
+							return arrayLengthError(slice.Type(), fmt.Sprintf("array of %d values", index))
+						}
 						val.Set(slice)
 						break DataLoop
 					}
@@ -308,104 +630,132 @@ func (dec *decoder) decodeValue(val reflect.Value) error {
 			return invalidXmlError
 		}
 
-		switch typeName {
-		case "int", "i4", "i8":
-			if checkType(val, reflect.Interface) == nil && val.IsNil() {
-				i, err := strconv.ParseInt(string(data), 10, 64)
-				if err != nil {
-					return err
-				}
+		if err = dec.decodeScalar(val, typeName, data, opts); err != nil {
+			return err
+		}
 
-				pi := reflect.New(reflect.TypeOf(i)).Elem()
-				pi.SetInt(i)
-				val.Set(pi)
-			} else if err = checkType(val, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64); err != nil {
-				return err
-			} else {
-				i, err := strconv.ParseInt(string(data), 10, val.Type().Bits())
-				if err != nil {
-					return err
-				}
+		// </type>
+		if err = dec.Skip(); err != nil {
+			return err
+		}
+	}
 
-				val.SetInt(i)
-			}
-		case "string", "base64":
-			str := string(data)
-			if checkType(val, reflect.Interface) == nil && val.IsNil() {
-				pstr := reflect.New(reflect.TypeOf(str)).Elem()
-				pstr.SetString(str)
-				val.Set(pstr)
-			} else if err = checkType(val, reflect.String); err != nil {
-				return err
-			} else {
-				val.SetString(str)
-			}
-		case "dateTime.iso8601":
-			var t time.Time
-			var err error
-
-			for _, layout := range timeLayouts {
-				t, err = time.Parse(layout, string(data))
-				if err == nil {
-					break
-				}
-			}
+	return nil
+}
+
+// decodeScalar decodes data of a scalar value of XML-RPC type typeName into
+// val. The </type> end element is left unread.
+func (dec *decoder) decodeScalar(val reflect.Value, typeName string, data []byte, opts tagOptions) (err error) {
+	if typeName == "string" || typeName == "base64" {
+		if err = checkLimit("MaxStringLength", len(data), dec.opts.Limits.MaxStringLength); err != nil {
+			return err
+		}
+	}
+
+	if ok, err := dec.decodeTagged(val, typeName, string(data), opts); ok {
+		return err
+	}
+
+	if typeName == "string" {
+		if u, ok := textUnmarshaler(val); ok {
+			return u.UnmarshalText(data)
+		}
+	}
+
+	if dec.opts.CoerceTypes {
+		if ok, err := dec.coerceValue(val, typeName, string(data), opts); ok {
+			return err
+		}
+	}
+
+	switch typeName {
+	case "int", "i4", "i8":
+		if checkType(val, reflect.Interface) == nil && val.IsNil() {
+			i, err := strconv.ParseInt(string(data), 10, 64)
 			if err != nil {
 				return err
 			}
 
-			if checkType(val, reflect.Interface) == nil && val.IsNil() {
-				ptime := reflect.New(reflect.TypeOf(t)).Elem()
-				ptime.Set(reflect.ValueOf(t))
-				val.Set(ptime)
-			} else if _, ok := val.Interface().(time.Time); !ok {
-				return TypeMismatchError(fmt.Sprintf("error: type mismatch error - can't decode %v to time", val.Kind()))
-			} else {
-				val.Set(reflect.ValueOf(t))
-			}
-		case "boolean":
-			v, err := strconv.ParseBool(string(data))
+			pi := reflect.New(reflect.TypeOf(i)).Elem()
+			pi.SetInt(i)
+			val.Set(pi)
+		} else if err = checkType(val, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64); err != nil {
+			return err
+		} else {
+			i, err := strconv.ParseInt(string(data), 10, val.Type().Bits())
 			if err != nil {
 				return err
 			}
 
-			if checkType(val, reflect.Interface) == nil && val.IsNil() {
-				pv := reflect.New(reflect.TypeOf(v)).Elem()
-				pv.SetBool(v)
-				val.Set(pv)
-			} else if err = checkType(val, reflect.Bool); err != nil {
+			val.SetInt(i)
+		}
+	case "string", "base64":
+		str := string(data)
+		if typeName == "base64" && isByteArray(val.Type()) {
+			if err = decodeByteArray(val, str); err != nil {
 				return err
-			} else {
-				val.SetBool(v)
 			}
-		case "double":
-			if checkType(val, reflect.Interface) == nil && val.IsNil() {
-				i, err := strconv.ParseFloat(string(data), 64)
-				if err != nil {
-					return err
-				}
+		} else if checkType(val, reflect.Interface) == nil && val.IsNil() {
+			pstr := reflect.New(reflect.TypeOf(str)).Elem()
+			pstr.SetString(str)
+			val.Set(pstr)
+		} else if err = checkType(val, reflect.String); err != nil {
+			return err
+		} else {
+			val.SetString(str)
+		}
+	case "dateTime.iso8601":
+		t, err := dec.parseTime(string(data), opts)
+		if err != nil {
+			return err
+		}
 
-				pdouble := reflect.New(reflect.TypeOf(i)).Elem()
-				pdouble.SetFloat(i)
-				val.Set(pdouble)
-			} else if err = checkType(val, reflect.Float32, reflect.Float64); err != nil {
-				return err
-			} else {
-				i, err := strconv.ParseFloat(string(data), val.Type().Bits())
-				if err != nil {
-					return err
-				}
+		if checkType(val, reflect.Interface) == nil && val.IsNil() {
+			ptime := reflect.New(reflect.TypeOf(t)).Elem()
+			ptime.Set(reflect.ValueOf(t))
+			val.Set(ptime)
+		} else if _, ok := val.Interface().(time.Time); !ok {
+			return TypeMismatchError(fmt.Sprintf("error: type mismatch error - can't decode %v to time", val.Kind()))
+		} else {
+			val.Set(reflect.ValueOf(t))
+		}
+	case "boolean":
+		v, err := strconv.ParseBool(string(data))
+		if err != nil {
+			return err
+		}
 
-				val.SetFloat(i)
-			}
-		default:
-			return errors.New("unsupported type")
+		if checkType(val, reflect.Interface) == nil && val.IsNil() {
+			pv := reflect.New(reflect.TypeOf(v)).Elem()
+			pv.SetBool(v)
+			val.Set(pv)
+		} else if err = checkType(val, reflect.Bool); err != nil {
+			return err
+		} else {
+			val.SetBool(v)
 		}
+	case "double":
+		if checkType(val, reflect.Interface) == nil && val.IsNil() {
+			i, err := strconv.ParseFloat(string(data), 64)
+			if err != nil {
+				return err
+			}
 
-		// </type>
-		if err = dec.Skip(); err != nil {
+			pdouble := reflect.New(reflect.TypeOf(i)).Elem()
+			pdouble.SetFloat(i)
+			val.Set(pdouble)
+		} else if err = checkType(val, reflect.Float32, reflect.Float64); err != nil {
 			return err
+		} else {
+			i, err := strconv.ParseFloat(string(data), val.Type().Bits())
+			if err != nil {
+				return err
+			}
+
+			val.SetFloat(i)
 		}
+	default:
+		return errors.New("unsupported type")
 	}
 
 	return nil
@@ -475,3 +825,252 @@ func checkType(val reflect.Value, kinds ...reflect.Kind) error {
 
 	return nil
 }
+
+// parseTime parses dateTime.iso8601 value with the layout from field's
+// "format" tag option, or with any of built-in and Decoder.TimeLayouts layouts.
+func (dec *decoder) parseTime(data string, opts tagOptions) (t time.Time, err error) {
+	loc := dec.opts.TimeLocation
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	if layout, ok := opts.Get("format"); ok {
+		return time.ParseInLocation(layout, data, loc)
+	}
+
+	for _, layouts := range [][]string{timeLayouts, dec.opts.TimeLayouts} {
+		for _, layout := range layouts {
+			t, err = time.ParseInLocation(layout, data, loc)
+			if err == nil {
+				return t, nil
+			}
+		}
+	}
+
+	return t, err
+}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// decodeTagged decodes data of XML-RPC type typeName into val, when the field
+// val belongs to is tagged with "string", "base64" or "unix" option to use
+// this type. It returns false if options don't apply, so that the value is
+// decoded as usual.
+func (dec *decoder) decodeTagged(val reflect.Value, typeName string, data string, opts tagOptions) (bool, error) {
+	if opts == "" || val.Kind() == reflect.Interface {
+		return false, nil
+	}
+
+	switch {
+	case opts.Contains("unix") && val.Type() == timeType:
+		if typeName != "int" && typeName != "i4" && typeName != "i8" {
+			return false, nil
+		}
+
+		i, err := strconv.ParseInt(strings.TrimSpace(data), 10, 64)
+		if err != nil {
+			return true, err
+		}
+
+		loc := dec.opts.TimeLocation
+		if loc == nil {
+			loc = time.UTC
+		}
+
+		val.Set(reflect.ValueOf(time.Unix(i, 0).In(loc)))
+		return true, nil
+	case opts.Contains("string") && typeName == "string":
+		data = strings.TrimSpace(data)
+
+		switch val.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			i, err := strconv.ParseInt(data, 10, val.Type().Bits())
+			if err != nil {
+				return true, err
+			}
+			val.SetInt(i)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			i, err := strconv.ParseUint(data, 10, val.Type().Bits())
+			if err != nil {
+				return true, err
+			}
+			val.SetUint(i)
+		case reflect.Float32, reflect.Float64:
+			f, err := strconv.ParseFloat(data, val.Type().Bits())
+			if err != nil {
+				return true, err
+			}
+			val.SetFloat(f)
+		case reflect.Bool:
+			b, err := strconv.ParseBool(data)
+			if err != nil {
+				return true, err
+			}
+			val.SetBool(b)
+		default:
+			return false, nil
+		}
+		return true, nil
+	case opts.Contains("base64") && typeName == "base64":
+		isBytes := val.Kind() == reflect.Slice && val.Type().Elem().Kind() == reflect.Uint8
+		if val.Kind() != reflect.String && !isBytes {
+			return false, nil
+		}
+
+		b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(data))
+		if err != nil {
+			return true, err
+		}
+
+		if isBytes {
+			val.SetBytes(b)
+		} else {
+			val.SetString(string(b))
+		}
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// coerceValue converts data of XML-RPC type typeName into val when the Go type
+// of val doesn't match typeName. It returns false if no conversion applies, so
+// that the value is decoded as usual.
+func (dec *decoder) coerceValue(val reflect.Value, typeName string, data string, opts tagOptions) (bool, error) {
+	if val.Kind() == reflect.Interface {
+		return false, nil
+	}
+
+	data = strings.TrimSpace(data)
+
+	switch typeName {
+	case "int", "i4", "i8":
+		switch val.Kind() {
+		case reflect.Float32, reflect.Float64:
+			i, err := strconv.ParseInt(data, 10, 64)
+			if err != nil {
+				return true, err
+			}
+
+			f := float64(i)
+			if val.Kind() == reflect.Float32 {
+				f = float64(float32(f))
+			}
+			if f >= math.MaxInt64 || int64(f) != i {
+				return true, lossError(typeName, data, val)
+			}
+
+			val.SetFloat(f)
+			return true, nil
+		case reflect.String:
+			val.SetString(data)
+			return true, nil
+		}
+	case "double":
+		switch val.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			f, err := strconv.ParseFloat(data, 64)
+			if err != nil {
+				return true, err
+			}
+
+			if f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 || val.OverflowInt(int64(f)) {
+				return true, lossError(typeName, data, val)
+			}
+
+			val.SetInt(int64(f))
+			return true, nil
+		case reflect.String:
+			val.SetString(data)
+			return true, nil
+		}
+	case "string":
+		if val.Type() == timeType {
+			t, err := dec.parseTime(data, opts)
+			if err != nil {
+				return true, err
+			}
+
+			val.Set(reflect.ValueOf(t))
+			return true, nil
+		}
+
+		switch val.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			i, err := strconv.ParseInt(data, 10, val.Type().Bits())
+			if err != nil {
+				return true, err
+			}
+
+			val.SetInt(i)
+			return true, nil
+		case reflect.Float32, reflect.Float64:
+			f, err := strconv.ParseFloat(data, val.Type().Bits())
+			if err != nil {
+				return true, err
+			}
+
+			val.SetFloat(f)
+			return true, nil
+		case reflect.Bool:
+			switch strings.ToLower(data) {
+			case "1", "true", "yes", "on":
+				val.SetBool(true)
+			case "0", "false", "no", "off":
+				val.SetBool(false)
+			default:
+				return true, TypeMismatchError(fmt.Sprintf("error: type mismatch - can't coerce string %q to bool", data))
+			}
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func lossError(typeName string, data string, val reflect.Value) error {
+	return TypeMismatchError(fmt.Sprintf("error: type mismatch - can't coerce %s %s to %v without loss of data",
+		typeName, data, val.Type()))
+}
+
+// mapKey converts member name into a key of map with key type t.
+func mapKey(t reflect.Type, name []byte) (reflect.Value, error) {
+	if t.Kind() == reflect.String {
+		return reflect.ValueOf(string(name)).Convert(t), nil
+	}
+
+	key := reflect.New(t)
+	if err := key.Interface().(encoding.TextUnmarshaler).UnmarshalText(name); err != nil {
+		return reflect.Value{}, err
+	}
+
+	return key.Elem(), nil
+}
+
+func isByteArray(t reflect.Type) bool {
+	return t.Kind() == reflect.Array && t.Elem().Kind() == reflect.Uint8
+}
+
+// decodeByteArray decodes base64 data into byte array val, which must have
+// the same length as decoded data.
+func decodeByteArray(val reflect.Value, data string) error {
+	b, err := base64.StdEncoding.DecodeString(strings.TrimSpace(data))
+	if err != nil {
+		return err
+	}
+	if len(b) != val.Len() {
+		return arrayLengthError(val.Type(), fmt.Sprintf("%d bytes", len(b)))
+	}
+
+	for i, c := range b {
+		val.Index(i).SetUint(uint64(c))
+	}
+
+	return nil
+}
+
+// arrayLengthError reports a value described by desc that doesn't fit into
+// Go array type t.
+func arrayLengthError(t reflect.Type, desc string) error {
+	return TypeMismatchError(fmt.Sprintf("error: type mismatch - can't unmarshal %s to %v", desc, t))
+}
@@ -4,7 +4,9 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"net"
 	"reflect"
+	"strings"
 	"testing"
 	"time"
 
@@ -22,6 +24,20 @@ type bookUnexported struct {
 	amount int
 }
 
+// point implements encoding.TextMarshaler and encoding.TextUnmarshaler.
+type point struct {
+	X, Y int
+}
+
+func (p point) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("%d,%d", p.X, p.Y)), nil
+}
+
+func (p *point) UnmarshalText(text []byte) error {
+	_, err := fmt.Sscanf(string(text), "%d,%d", &p.X, &p.Y)
+	return err
+}
+
 var unmarshalTests = []struct {
 	value interface{}
 	ptr   interface{}
@@ -63,12 +79,21 @@ var unmarshalTests = []struct {
 	{[]int{1, 5, 7}, new(*[]int), "<value><array><data><value><int>1</int></value><value><int>5</int></value><value><int>7</int></value></data></array></value>"},
 	{[]interface{}{"A", "5"}, new(interface{}), "<value><array><data><value><string>A</string></value><value><string>5</string></value></data></array></value>"},
 	{[]interface{}{"A", int64(5)}, new(interface{}), "<value><array><data><value><string>A</string></value><value><int>5</int></value></data></array></value>"},
+	{[3]float64{1.5, 2, -3}, new(*[3]float64), "<value><array><data><value><double>1.5</double></value><value><double>2</double></value><value><double>-3</double></value></data></array></value>"},
+	{[2]interface{}{"A", int64(5)}, new(*[2]interface{}), "<value><array><data><value><string>A</string></value><value><int>5</int></value></data></array></value>"},
+	{[5]byte{'h', 'e', 'l', 'l', 'o'}, new(*[5]byte), "<value><base64>aGVsbG8=</base64></value>"},
 
 	// struct
 	{book{"War and Piece", 20}, new(*book), "<value><struct><member><name>Title</name><value><string>War and Piece</string></value></member><member><name>Amount</name><value><int>20</int></value></member></struct></value>"},
 	{bookUnexported{}, new(*bookUnexported), "<value><struct><member><name>title</name><value><string>War and Piece</string></value></member><member><name>amount</name><value><int>20</int></value></member></struct></value>"},
 	{map[string]interface{}{"Name": "John Smith"}, new(interface{}), "<value><struct><member><name>Name</name><value><string>John Smith</string></value></member></struct></value>"},
 	{map[string]interface{}{}, new(interface{}), "<value><struct></struct></value>"},
+
+	// encoding.TextUnmarshaler
+	{point{1, 2}, new(*point), "<value><string>1,2</string></value>"},
+	{point{1, 2}, new(*point), "<value>1,2</value>"},
+	{net.ParseIP("10.0.0.1"), new(*net.IP), "<value><string>10.0.0.1</string></value>"},
+	{map[point]int{{1, 2}: 3}, new(*map[point]int), "<value><struct><member><name>1,2</name><value><int>3</int></value></member></struct></value>"},
 }
 
 func _time(s string) time.Time {
@@ -225,6 +250,22 @@ func Test_decodeNonUTF8Response(t *testing.T) {
 	CharsetReader = nil
 }
 
+func Test_decodeNonUTF8ResponseWithBuiltinCharsets(t *testing.T) {
+	data, err := ioutil.ReadFile("fixtures/cp1251.xml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var s string
+	if err = unmarshal(data, &s); err != nil {
+		t.Fatalf("unmarshal error: %v", err)
+	}
+
+	if expected := "Л.Н. Толстой - Война и Мир"; s != expected {
+		t.Fatalf("unmarshal error:\nexpected: %v\n     got: %v", expected, s)
+	}
+}
+
 func decode(charset string, input io.Reader) (io.Reader, error) {
 	if charset != "cp1251" {
 		return nil, fmt.Errorf("unsupported charset")
@@ -232,3 +273,254 @@ func decode(charset string, input io.Reader) (io.Reader, error) {
 
 	return transform.NewReader(input, charmap.Windows1251.NewDecoder()), nil
 }
+
+const bookXML = `
+<value>
+  <struct>
+    <member><name>Title</name><value><string>War and Piece</string></value></member>
+    <member><name>Amount</name><value><int>20</int></value></member>
+    <member><name>Author</name><value><string>Leo Tolstoy</string></value></member>
+    <member><name>Year</name><value><int>1869</int></value></member>
+  </struct>
+</value>
+`
+
+func Test_decodeUnknownMembers(t *testing.T) {
+	var b book
+
+	// unknown members are skipped by default
+	if err := unmarshal([]byte(bookXML), &b); err != nil {
+		t.Fatalf("unmarshal error: %v", err)
+	}
+
+	dec := &Decoder{DisallowUnknownMembers: true}
+	err := dec.Unmarshal([]byte(bookXML), &b)
+	if err == nil {
+		t.Fatal("unmarshal error: expected error, but didn't get it")
+	}
+
+	merr, ok := err.(UnknownMembersError)
+	if !ok {
+		t.Fatalf("unmarshal error: expected UnknownMembersError, got %T", err)
+	}
+	if !reflect.DeepEqual(merr.Members, []string{"Author", "Year"}) {
+		t.Fatalf("unexpected unknown members: %v", merr.Members)
+	}
+}
+
+func Test_decodeRequiredMembers(t *testing.T) {
+	var v struct {
+		Title     string `xmlrpc:",required"`
+		Publisher string `xmlrpc:"publisher,required"`
+		Pages     int    `xmlrpc:"pages,omitempty,required"`
+	}
+
+	err := unmarshal([]byte(bookXML), &v)
+	if err == nil {
+		t.Fatal("unmarshal error: expected error, but didn't get it")
+	}
+
+	merr, ok := err.(MissingMembersError)
+	if !ok {
+		t.Fatalf("unmarshal error: expected MissingMembersError, got %T", err)
+	}
+	if !reflect.DeepEqual(merr.Members, []string{"publisher", "pages"}) {
+		t.Fatalf("unexpected missing members: %v", merr.Members)
+	}
+	if v.Title != "War and Piece" {
+		t.Fatalf("unexpected title: %q", v.Title)
+	}
+}
+
+var coerceTests = []struct {
+	value interface{}
+	xml   string
+}{
+	{12.0, "<value><int>12</int></value>"},
+	{float32(-7), "<value><i4>-7</i4></value>"},
+	{12, "<value><double>12.0</double></value>"},
+	{"12", "<value><int>12</int></value>"},
+	{"1.5", "<value><double>1.5</double></value>"},
+	{42, "<value><string>42</string></value>"},
+	{int8(-3), "<value> -3 </value>"},
+	{2.5, "<value><string>2.5</string></value>"},
+	{true, "<value><string>yes</string></value>"},
+	{true, "<value><string>True</string></value>"},
+	{false, "<value><string>off</string></value>"},
+	{_time("2013-12-09T21:00:12Z"), "<value><string>20131209T21:00:12</string></value>"},
+}
+
+func Test_decodeCoerceTypes(t *testing.T) {
+	dec := &Decoder{CoerceTypes: true}
+
+	for _, tt := range coerceTests {
+		v := reflect.New(reflect.TypeOf(tt.value))
+		if err := dec.Unmarshal([]byte(tt.xml), v.Interface()); err != nil {
+			t.Fatalf("unmarshal error for %s: %v", tt.xml, err)
+		}
+
+		if !reflect.DeepEqual(v.Elem().Interface(), tt.value) {
+			t.Fatalf("unmarshal error:\nexpected: %v\n     got: %v", tt.value, v.Elem().Interface())
+		}
+	}
+}
+
+func Test_decodeCoerceLoss(t *testing.T) {
+	dec := &Decoder{CoerceTypes: true}
+
+	var i int
+	if err := dec.Unmarshal([]byte("<value><double>1.5</double></value>"), &i); err == nil {
+		t.Fatal("expected truncation error, but didn't get it")
+	}
+
+	var i8 int8
+	if err := dec.Unmarshal([]byte("<value><double>300</double></value>"), &i8); err == nil {
+		t.Fatal("expected overflow error, but didn't get it")
+	}
+	if err := dec.Unmarshal([]byte("<value><string>300</string></value>"), &i8); err == nil {
+		t.Fatal("expected overflow error, but didn't get it")
+	}
+
+	var f float64
+	if err := dec.Unmarshal([]byte("<value><i8>9007199254740993</i8></value>"), &f); err == nil {
+		t.Fatal("expected precision loss error, but didn't get it")
+	}
+
+	var b bool
+	if err := dec.Unmarshal([]byte("<value><string>maybe</string></value>"), &b); err == nil {
+		t.Fatal("expected error, but didn't get it")
+	}
+
+	// without coercion type mismatch is still reported
+	if err := unmarshal([]byte("<value><int>12</int></value>"), &f); err == nil {
+		t.Fatal("expected type mismatch error, but didn't get it")
+	}
+}
+
+func Test_decodeLimits(t *testing.T) {
+	tests := []struct {
+		limits Limits
+		xml    string
+		limit  string
+	}{
+		{Limits{MaxBodySize: 10}, arrayValueXML, "MaxBodySize"},
+		{Limits{MaxDepth: 2}, "<value><array><data><value><array><data></data></array></value></data></array></value>", ""},
+		{Limits{MaxDepth: 2}, "<value><array><data><value><array><data><value><int>1</int></value></data></array></value></data></array></value>", "MaxDepth"},
+		{Limits{MaxArrayLength: 4}, arrayValueXML, ""},
+		{Limits{MaxArrayLength: 3}, arrayValueXML, "MaxArrayLength"},
+		{Limits{MaxStructMembers: 4}, bookXML, ""},
+		{Limits{MaxStructMembers: 3}, bookXML, "MaxStructMembers"},
+		{Limits{MaxStringLength: 5}, "<value><string>Hello</string></value>", ""},
+		{Limits{MaxStringLength: 4}, "<value><string>Hello</string></value>", "MaxStringLength"},
+		{Limits{MaxStringLength: 4}, "<value><base64>SGVsbG8=</base64></value>", "MaxStringLength"},
+		{Limits{MaxStringLength: 4}, "<value>Hello</value>", "MaxStringLength"},
+	}
+
+	for _, tt := range tests {
+		dec := &Decoder{Limits: tt.limits}
+
+		var v interface{}
+		err := dec.Unmarshal([]byte(tt.xml), &v)
+
+		if tt.limit == "" {
+			if err != nil {
+				t.Fatalf("unexpected unmarshal error: %v", err)
+			}
+			continue
+		}
+
+		lerr, ok := err.(LimitError)
+		if !ok {
+			t.Fatalf("expected LimitError for %s, got %v", tt.limit, err)
+		}
+		if lerr.Limit != tt.limit {
+			t.Fatalf("expected %s to be exceeded, got %s", tt.limit, lerr.Limit)
+		}
+	}
+}
+
+func Test_decoderReadBody(t *testing.T) {
+	dec := &Decoder{Limits: Limits{MaxBodySize: 5}}
+
+	if _, err := dec.ReadBody(strings.NewReader("12345")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := dec.ReadBody(strings.NewReader("123456")); err == nil {
+		t.Fatal("expected LimitError, but didn't get it")
+	}
+}
+
+func Test_unmarshalArrayWithEmptyValue(t *testing.T) {
+	var v []interface{}
+
+	encoded := "<value><array><data><value/><value><int>1</int></value></data></array></value>"
+	if err := unmarshal([]byte(encoded), &v); err != nil {
+		t.Fatalf("unmarshal error: %v", err)
+	}
+
+	if len(v) != 2 || v[0] != nil || v[1] != int64(1) {
+		t.Fatalf("unexpected result: %v", v)
+	}
+}
+
+func Test_unmarshalArrayLengthMismatch(t *testing.T) {
+	tests := []struct {
+		ptr interface{}
+		xml string
+	}{
+		{new([2]int), "<value><array><data><value><int>1</int></value><value><int>2</int></value><value><int>3</int></value></data></array></value>"},
+		{new([4]int), "<value><array><data><value><int>1</int></value><value><int>2</int></value><value><int>3</int></value></data></array></value>"},
+		{new([4]byte), "<value><base64>aGVsbG8=</base64></value>"},
+	}
+
+	for _, tt := range tests {
+		err := unmarshal([]byte(tt.xml), tt.ptr)
+		if _, ok := err.(TypeMismatchError); !ok {
+			t.Fatalf("expected type mismatch error for %T, got %v", tt.ptr, err)
+		}
+	}
+}
+
+func Test_decoderTimeOptions(t *testing.T) {
+	moscow := time.FixedZone("MSK", 3*60*60)
+
+	tests := []struct {
+		dec   *Decoder
+		value time.Time
+		xml   string
+	}{
+		{&Decoder{}, _time("2013-12-09T00:00:00Z"), "<value><dateTime.iso8601>20131209</dateTime.iso8601></value>"},
+		{&Decoder{}, _time("2013-12-09T00:00:00Z"), "<value><dateTime.iso8601>2013-12-09</dateTime.iso8601></value>"},
+		{&Decoder{}, _time("2013-12-09T21:00:12.345678Z"), "<value><dateTime.iso8601>20131209T21:00:12.345678</dateTime.iso8601></value>"},
+		{&Decoder{TimeLocation: moscow}, _time("2013-12-09T21:00:12+03:00"), "<value><dateTime.iso8601>20131209T21:00:12</dateTime.iso8601></value>"},
+		{&Decoder{TimeLocation: moscow}, _time("2013-12-09T21:00:12Z"), "<value><dateTime.iso8601>20131209T21:00:12Z</dateTime.iso8601></value>"},
+		{&Decoder{TimeLayouts: []string{"02.01.2006 15:04"}}, _time("2013-12-09T21:00:00Z"), "<value><dateTime.iso8601>09.12.2013 21:00</dateTime.iso8601></value>"},
+	}
+
+	for _, tt := range tests {
+		var v time.Time
+		if err := tt.dec.Unmarshal([]byte(tt.xml), &v); err != nil {
+			t.Fatalf("unmarshal error: %v", err)
+		}
+
+		if !v.Equal(tt.value) {
+			t.Fatalf("unmarshal error:\nexpected: %v\n     got: %v", tt.value, v)
+		}
+	}
+}
+
+func Test_decodeTimeFormatTag(t *testing.T) {
+	var v struct {
+		Date time.Time `xmlrpc:"date,format=02.01.2006"`
+	}
+
+	encoded := "<value><struct><member><name>date</name><value><dateTime.iso8601>09.12.2013</dateTime.iso8601></value></member></struct></value>"
+	if err := unmarshal([]byte(encoded), &v); err != nil {
+		t.Fatalf("unmarshal error: %v", err)
+	}
+
+	if expected := _time("2013-12-09T00:00:00Z"); !v.Date.Equal(expected) {
+		t.Fatalf("unmarshal error:\nexpected: %v\n     got: %v", expected, v.Date)
+	}
+}
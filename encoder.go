@@ -2,6 +2,8 @@ package xmlrpc
 
 import (
 	"bytes"
+	"context"
+	"encoding/base64"
 	"encoding/xml"
 	"fmt"
 	"reflect"
@@ -9,47 +11,199 @@ import (
 	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
 )
 
 // Base64 represents value in base64 encoding
 type Base64 string
 
+// EncodeError is returned when a value can't be encoded into valid XML-RPC.
+type EncodeError struct {
+	// Path locates the value that failed to encode, e.g. "params[0].users[2]".
+	// It is empty for the top level value.
+	Path string
+	Msg  string
+}
+
+func (e EncodeError) Error() string {
+	if e.Path == "" {
+		return "xmlrpc encode error: " + e.Msg
+	}
+	return fmt.Sprintf("xmlrpc encode error: %s at %s", e.Msg, e.Path)
+}
+
 type encodeFunc func(reflect.Value) ([]byte, error)
 
+// Encoder holds options that control encoding of Go values into XML-RPC
+// values. The zero value encodes values the same way EncodeMethodCall does.
+type Encoder struct {
+	// TimeLayout is the layout used to format time.Time values, e.g.
+	// "20060102T15:04:05Z07:00" to keep time zone offset, or
+	// "20060102T15:04:05.000000" to add microseconds. If empty, the zone-less
+	// "20060102T15:04:05" layout is used.
+	TimeLayout string
+
+	// TimeLocation, if set, is the location time.Time values are converted
+	// to before formatting.
+	TimeLocation *time.Location
+
+	// StrictMethodNames makes EncodeMethodCall fail for method names with
+	// characters other than the ones allowed by XML-RPC specification:
+	// letters, digits, underscore, dot, colon and slash.
+	StrictMethodNames bool
+
+	// MaxDepth is the maximum nesting depth of encoded values. Zero means
+	// there is no limit.
+	MaxDepth int
+
+	// Charset is the character encoding of method calls, e.g. "windows-1251"
+	// or "ISO-8859-1", for servers that don't accept UTF-8. Encoding fails for
+	// characters that can't be represented in the charset. If empty, UTF-8
+	// is used.
+	Charset string
+
+	// MapOrder is the order of members of structs encoded from Go maps,
+	// wherever they are nested. Use OrderedMap to keep insertion order, or
+	// WithMapOrder to change it for a single call.
+	MapOrder MapOrder
+
+	// ContentEncoding, if set to "gzip" or "deflate", makes NewRequest
+	// compress request bodies and set Content-Encoding header. Use it only
+	// with servers that accept compressed requests.
+	ContentEncoding string
+}
+
+// Marshal returns XML-RPC encoding of v as a <value> element.
+func (e *Encoder) Marshal(v interface{}) ([]byte, error) {
+	return e.marshal(v)
+}
+
+type encoder struct {
+	opts *Encoder
+
+	// depth is the nesting depth of the value being encoded.
+	depth int
+
+	// visited holds pointers, maps and slices on the way to the value being
+	// encoded, to detect cycles.
+	visited map[visitKey]bool
+
+	// path holds member names and array indexes leading to the value being
+	// encoded.
+	path []pathElem
+}
+
+// pathElem is either a member name or, if name is empty, an array index.
+type pathElem struct {
+	name  string
+	index int
+}
+
+type visitKey struct {
+	ptr uintptr
+	typ reflect.Type
+	len int
+}
+
 func marshal(v interface{}) ([]byte, error) {
+	return new(Encoder).marshal(v)
+}
+
+// marshal encodes v. path is the location of v reported in EncodeError.
+func (e *Encoder) marshal(v interface{}, path ...pathElem) ([]byte, error) {
 	if v == nil {
 		return []byte{}, nil
 	}
 
+	enc := &encoder{opts: e, path: path}
+
 	val := reflect.ValueOf(v)
-	return encodeValue(val)
+	return enc.encodeValue(val, "")
 }
 
-func encodeValue(val reflect.Value) ([]byte, error) {
+// encodeValue encodes val. opts holds tag options of the struct field val
+// belongs to, if any.
+func (enc *encoder) encodeValue(val reflect.Value, opts tagOptions) ([]byte, error) {
 	var b []byte
 	var err error
 
-	if val.Kind() == reflect.Ptr || val.Kind() == reflect.Interface {
+	enc.depth++
+	defer func() { enc.depth-- }()
+
+	if max := enc.opts.MaxDepth; max > 0 && enc.depth > max {
+		return nil, enc.errorf("maximum depth of %d exceeded", max)
+	}
+
+	for val.Kind() == reflect.Ptr || val.Kind() == reflect.Interface {
 		if val.IsNil() {
 			return []byte("<value/>"), nil
 		}
 
+		if val.Kind() == reflect.Ptr {
+			key, err := enc.visit(val)
+			if err != nil {
+				return nil, err
+			}
+			defer delete(enc.visited, key)
+		}
+
 		val = val.Elem()
 	}
 
+	if (val.Kind() == reflect.Map || val.Kind() == reflect.Slice) && !val.IsNil() {
+		key, err := enc.visit(val)
+		if err != nil {
+			return nil, err
+		}
+		defer delete(enc.visited, key)
+	}
+
+	if b, ok := enc.encodeTagged(val, opts); ok {
+		return []byte(fmt.Sprintf("<value>%s</value>", string(b))), nil
+	}
+
+	if m, ok := marshaler(val); ok {
+		return enc.encodeMarshaler(m)
+	}
+
+	if m, ok := textMarshaler(val); ok {
+		text, err := m.MarshalText()
+		if err != nil {
+			return nil, err
+		}
+
+		var buf bytes.Buffer
+		xml.Escape(&buf, text)
+
+		return []byte(fmt.Sprintf("<value><string>%s</string></value>", buf.String())), nil
+	}
+
 	switch val.Kind() {
 	case reflect.Struct:
 		switch val.Interface().(type) {
 		case time.Time:
 			t := val.Interface().(time.Time)
-			b = []byte(fmt.Sprintf("<dateTime.iso8601>%s</dateTime.iso8601>", t.Format(iso8601)))
+			b = []byte(fmt.Sprintf("<dateTime.iso8601>%s</dateTime.iso8601>", enc.formatTime(t, opts)))
+		case OrderedMap:
+			m := val.Interface().(OrderedMap)
+			b, err = enc.encodeMembers(m.members())
 		default:
-			b, err = encodeStruct(val)
+			b, err = enc.encodeStruct(val)
 		}
 	case reflect.Map:
-		b, err = encodeMap(val)
+		b, err = enc.encodeMap(val)
 	case reflect.Slice:
-		b, err = encodeSlice(val)
+		if val.Type() == orderedStructType {
+			b, err = enc.encodeMembers(val.Interface().(OrderedStruct).members())
+		} else {
+			b, err = enc.encodeSlice(val)
+		}
+	case reflect.Array:
+		if isByteArray(val.Type()) {
+			b = encodeByteArray(val)
+		} else {
+			b, err = enc.encodeSlice(val)
+		}
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
 		b = []byte(fmt.Sprintf("<int>%s</int>", strconv.FormatInt(val.Int(), 10)))
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
@@ -74,7 +228,7 @@ func encodeValue(val reflect.Value) ([]byte, error) {
 			b = []byte(fmt.Sprintf("<string>%s</string>", buf.String()))
 		}
 	default:
-		return nil, fmt.Errorf("xmlrpc encode error: unsupported type")
+		return nil, enc.errorf("unsupported type %v", val.Type())
 	}
 
 	if err != nil {
@@ -84,34 +238,35 @@ func encodeValue(val reflect.Value) ([]byte, error) {
 	return []byte(fmt.Sprintf("<value>%s</value>", string(b))), nil
 }
 
-func encodeStruct(structVal reflect.Value) ([]byte, error) {
+func (enc *encoder) encodeStruct(structVal reflect.Value) ([]byte, error) {
 	var b bytes.Buffer
 
 	b.WriteString("<struct>")
 
-	structType := structVal.Type()
-	for i := 0; i < structType.NumField(); i++ {
-		fieldVal := structVal.Field(i)
-		fieldType := structType.Field(i)
-
-		name := fieldType.Tag.Get("xmlrpc")
-		// skip ignored fields.
-		if name == "-" {
+	fields := cachedTypeFields(structVal.Type())
+	for i := range fields.list {
+		f := &fields.list[i]
+		fieldVal, ok := fieldByIndex(structVal, f.index)
+		if !ok {
 			continue
 		}
+
 		// if the tag has the omitempty property, skip it
-		if strings.HasSuffix(name, ",omitempty") && fieldVal.IsZero() {
+		if f.omitEmpty && fieldVal.IsZero() {
 			continue
 		}
-		name = strings.TrimSuffix(name, ",omitempty")
-		if name == "" {
-			name = fieldType.Name
-		}
 
-		p, err := encodeValue(fieldVal)
+		enc.path = append(enc.path, pathElem{name: f.name})
+		p, err := enc.encodeValue(fieldVal, f.opts)
 		if err != nil {
 			return nil, err
 		}
+		enc.path = enc.path[:len(enc.path)-1]
+
+		name, err := escapeName(f.name)
+		if err != nil {
+			return nil, enc.pathError(err)
+		}
 
 		b.WriteString("<member>")
 		b.WriteString(fmt.Sprintf("<name>%s</name>", name))
@@ -124,37 +279,84 @@ func encodeStruct(structVal reflect.Value) ([]byte, error) {
 	return b.Bytes(), nil
 }
 
-var sortMapKeys bool
+// MapOrder defines order of struct members encoded from Go maps.
+type MapOrder int
 
-func encodeMap(val reflect.Value) ([]byte, error) {
-	var t = val.Type()
+const (
+	// MapOrderRandom keeps iteration order of Go maps, which is random.
+	MapOrderRandom MapOrder = iota
+
+	// MapOrderSorted sorts members by name, making encoding reproducible.
+	MapOrderSorted
+)
+
+// WithMapOrder returns a copy of ctx, which makes calls of Client.CallContext
+// and Client.StreamArrayContext encode Go maps in order instead of
+// Encoder.MapOrder of the client.
+func WithMapOrder(ctx context.Context, order MapOrder) context.Context {
+	return context.WithValue(ctx, mapOrderKey, order)
+}
 
-	if t.Key().Kind() != reflect.String {
-		return nil, fmt.Errorf("xmlrpc encode error: only maps with string keys are supported")
+// withContext returns e with options overridden by ctx.
+func (e *Encoder) withContext(ctx context.Context) *Encoder {
+	if order, ok := ctx.Value(mapOrderKey).(MapOrder); ok && order != e.MapOrder {
+		c := *e
+		c.MapOrder = order
+		return &c
 	}
+	return e
+}
 
-	var b bytes.Buffer
+// member is a struct member encoded from a map.
+type member struct {
+	name string
+	val  reflect.Value
+}
 
-	b.WriteString("<struct>")
+func (enc *encoder) encodeMap(val reflect.Value) ([]byte, error) {
+	var t = val.Type()
 
-	keys := val.MapKeys()
+	if t.Key().Kind() != reflect.String && !t.Key().Implements(textMarshalerType) {
+		return nil, enc.errorf("only maps with string keys or keys implementing encoding.TextMarshaler are supported")
+	}
 
-	if sortMapKeys {
-		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+	members := make([]member, 0, val.Len())
+	for _, key := range val.MapKeys() {
+		name, err := mapKeyName(key)
+		if err != nil {
+			return nil, enc.pathError(err)
+		}
+		members = append(members, member{name: name, val: val.MapIndex(key)})
 	}
 
-	for i := 0; i < val.Len(); i++ {
-		key := keys[i]
-		kval := val.MapIndex(key)
+	if enc.opts.MapOrder == MapOrderSorted {
+		sort.Slice(members, func(i, j int) bool { return members[i].name < members[j].name })
+	}
 
-		b.WriteString("<member>")
-		b.WriteString(fmt.Sprintf("<name>%s</name>", key.String()))
+	return enc.encodeMembers(members)
+}
+
+// encodeMembers encodes struct with members in the given order.
+func (enc *encoder) encodeMembers(members []member) ([]byte, error) {
+	var b bytes.Buffer
+
+	b.WriteString("<struct>")
+
+	for _, m := range members {
+		name, err := escapeName(m.name)
+		if err != nil {
+			return nil, enc.pathError(err)
+		}
 
-		p, err := encodeValue(kval)
+		b.WriteString("<member>")
+		b.WriteString(fmt.Sprintf("<name>%s</name>", name))
 
+		enc.path = append(enc.path, pathElem{name: m.name})
+		p, err := enc.encodeValue(m.val, "")
 		if err != nil {
 			return nil, err
 		}
+		enc.path = enc.path[:len(enc.path)-1]
 
 		b.Write(p)
 		b.WriteString("</member>")
@@ -165,16 +367,37 @@ func encodeMap(val reflect.Value) ([]byte, error) {
 	return b.Bytes(), nil
 }
 
-func encodeSlice(val reflect.Value) ([]byte, error) {
+// mapKeyName returns member name for map key.
+func mapKeyName(key reflect.Value) (string, error) {
+	if key.Kind() == reflect.String {
+		return key.String(), nil
+	}
+
+	m, ok := textMarshaler(key)
+	if !ok {
+		return "", EncodeError{Msg: fmt.Sprintf("unsupported map key type %v", key.Type())}
+	}
+
+	text, err := m.MarshalText()
+	if err != nil {
+		return "", err
+	}
+
+	return string(text), nil
+}
+
+func (enc *encoder) encodeSlice(val reflect.Value) ([]byte, error) {
 	var b bytes.Buffer
 
 	b.WriteString("<array><data>")
 
 	for i := 0; i < val.Len(); i++ {
-		p, err := encodeValue(val.Index(i))
+		enc.path = append(enc.path, pathElem{index: i})
+		p, err := enc.encodeValue(val.Index(i), "")
 		if err != nil {
 			return nil, err
 		}
+		enc.path = enc.path[:len(enc.path)-1]
 
 		b.Write(p)
 	}
@@ -183,3 +406,196 @@ func encodeSlice(val reflect.Value) ([]byte, error) {
 
 	return b.Bytes(), nil
 }
+
+// encodeByteArray encodes byte array val as base64.
+func encodeByteArray(val reflect.Value) []byte {
+	data := make([]byte, val.Len())
+	for i := range data {
+		data[i] = byte(val.Index(i).Uint())
+	}
+
+	return []byte(fmt.Sprintf("<base64>%s</base64>", base64.StdEncoding.EncodeToString(data)))
+}
+
+// formatTime formats t with the layout from field's "format" tag option, or
+// with Encoder.TimeLayout.
+func (enc *encoder) formatTime(t time.Time, opts tagOptions) string {
+	layout, ok := opts.Get("format")
+	if !ok {
+		layout = enc.opts.TimeLayout
+	}
+	if layout == "" {
+		layout = iso8601
+	}
+
+	if enc.opts.TimeLocation != nil {
+		t = t.In(enc.opts.TimeLocation)
+	}
+
+	return t.Format(layout)
+}
+
+// encodeTagged encodes val with XML-RPC type requested by "string", "base64",
+// "unix" or "i8" tag options. It returns false if options don't apply to the
+// type of val.
+func (enc *encoder) encodeTagged(val reflect.Value, opts tagOptions) ([]byte, bool) {
+	if opts == "" {
+		return nil, false
+	}
+
+	intType := "int"
+	if opts.Contains("i8") {
+		intType = "i8"
+	}
+
+	var s string
+
+	switch {
+	case opts.Contains("unix") && val.Type() == timeType:
+		t := val.Interface().(time.Time)
+		return []byte(fmt.Sprintf("<%s>%d</%s>", intType, t.Unix(), intType)), true
+	case opts.Contains("string"):
+		switch val.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			s = strconv.FormatInt(val.Int(), 10)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			s = strconv.FormatUint(val.Uint(), 10)
+		case reflect.Float32, reflect.Float64:
+			s = strconv.FormatFloat(val.Float(), 'f', -1, val.Type().Bits())
+		case reflect.Bool:
+			s = strconv.FormatBool(val.Bool())
+		default:
+			return nil, false
+		}
+		return []byte(fmt.Sprintf("<string>%s</string>", s)), true
+	case opts.Contains("base64"):
+		switch {
+		case val.Kind() == reflect.String:
+			s = base64.StdEncoding.EncodeToString([]byte(val.String()))
+		case val.Kind() == reflect.Slice && val.Type().Elem().Kind() == reflect.Uint8:
+			s = base64.StdEncoding.EncodeToString(val.Bytes())
+		default:
+			return nil, false
+		}
+		return []byte(fmt.Sprintf("<base64>%s</base64>", s)), true
+	case opts.Contains("i8"):
+		switch val.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			s = strconv.FormatInt(val.Int(), 10)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			s = strconv.FormatUint(val.Uint(), 10)
+		default:
+			return nil, false
+		}
+		return []byte(fmt.Sprintf("<i8>%s</i8>", s)), true
+	}
+
+	return nil, false
+}
+
+// visit marks pointer, map or slice val as being encoded, and fails if it is
+// already on the way to the current value. The returned key must be deleted
+// from visited once val is encoded.
+func (enc *encoder) visit(val reflect.Value) (visitKey, error) {
+	key := visitKey{ptr: val.Pointer(), typ: val.Type()}
+	if val.Kind() == reflect.Slice {
+		key.len = val.Len()
+	}
+
+	if enc.visited[key] {
+		return key, enc.errorf("cycle detected in value of type %v", val.Type())
+	}
+
+	if enc.visited == nil {
+		enc.visited = make(map[visitKey]bool)
+	}
+	enc.visited[key] = true
+
+	return key, nil
+}
+
+// errorf returns EncodeError for the value being encoded.
+func (enc *encoder) errorf(format string, args ...interface{}) error {
+	return enc.pathError(EncodeError{Msg: fmt.Sprintf(format, args...)})
+}
+
+// pathError sets location of the value being encoded to EncodeError err.
+func (enc *encoder) pathError(err error) error {
+	if e, ok := err.(EncodeError); ok && e.Path == "" {
+		e.Path = enc.pathString()
+		return e
+	}
+	return err
+}
+
+func (enc *encoder) pathString() string {
+	var b strings.Builder
+	for _, p := range enc.path {
+		if p.name == "" {
+			fmt.Fprintf(&b, "[%d]", p.index)
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteByte('.')
+		}
+		b.WriteString(p.name)
+	}
+	return b.String()
+}
+
+// escapeName escapes struct member or method name. It fails for names that
+// contain characters not allowed in XML documents.
+func escapeName(name string) (string, error) {
+	if !isValidXMLString(name) {
+		return "", EncodeError{Msg: fmt.Sprintf("name %q contains characters not allowed in XML", name)}
+	}
+
+	if !strings.ContainsAny(name, "<>&'\"\t\n\r") {
+		return name, nil
+	}
+
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(name))
+
+	return buf.String(), nil
+}
+
+// isValidXMLString reports whether s is valid UTF-8 consisting of characters
+// allowed in XML 1.0 documents.
+func isValidXMLString(s string) bool {
+	for i, r := range s {
+		if r == utf8.RuneError {
+			if _, size := utf8.DecodeRuneInString(s[i:]); size == 1 {
+				return false
+			}
+		}
+
+		if !(r == 0x09 || r == 0x0A || r == 0x0D ||
+			r >= 0x20 && r <= 0xD7FF ||
+			r >= 0xE000 && r <= 0xFFFD ||
+			r >= 0x10000 && r <= 0x10FFFF) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isValidMethodName reports whether name consists of characters permitted by
+// XML-RPC specification: letters, digits, underscore, dot, colon and slash.
+func isValidMethodName(name string) bool {
+	if name == "" {
+		return false
+	}
+
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '_', r == '.', r == ':', r == '/':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
@@ -1,6 +1,8 @@
 package xmlrpc
 
 import (
+	"net"
+	"reflect"
 	"testing"
 	"time"
 )
@@ -20,6 +22,8 @@ var marshalTests = []struct {
 	{738777323.0, "<value><double>738777323</double></value>"},
 	{time.Unix(1386622812, 0).UTC(), "<value><dateTime.iso8601>20131209T21:00:12</dateTime.iso8601></value>"},
 	{[]interface{}{1, "one"}, "<value><array><data><value><int>1</int></value><value><string>one</string></value></data></array></value>"},
+	{[3]float64{1.5, 2, -3}, "<value><array><data><value><double>1.5</double></value><value><double>2</double></value><value><double>-3</double></value></data></array></value>"},
+	{[5]byte{'h', 'e', 'l', 'l', 'o'}, "<value><base64>aGVsbG8=</base64></value>"},
 	{&struct {
 		Title  string
 		Amount int
@@ -55,6 +59,12 @@ var marshalTests = []struct {
 	}, "<value><struct><member><name>Title</name><value><string>War and Piece</string></value></member><member><name>Amount</name><value><int>20</int></value></member><member><name>author</name><value><string>Leo Tolstoy</string></value></member></struct></value>"},
 	{&struct {
 	}{}, "<value><struct></struct></value>"},
+	{point{1, 2}, "<value><string>1,2</string></value>"},
+	{net.ParseIP("10.0.0.1"), "<value><string>10.0.0.1</string></value>"},
+	{map[point]int{{1, 2}: 3}, "<value><struct><member><name>1,2</name><value><int>3</int></value></member></struct></value>"},
+	{&struct {
+		Position point `xmlrpc:"position"`
+	}{point{1, 2}}, "<value><struct><member><name>position</name><value><string>1,2</string></value></member></struct></value>"},
 	{&struct {
 		ID   int    `xmlrpc:"id"`
 		Name string `xmlrpc:"-"`
@@ -64,10 +74,10 @@ var marshalTests = []struct {
 }
 
 func Test_marshal(t *testing.T) {
-	sortMapKeys = true
+	enc := &Encoder{MapOrder: MapOrderSorted}
 
 	for _, tt := range marshalTests {
-		b, err := marshal(tt.value)
+		b, err := enc.Marshal(tt.value)
 		if err != nil {
 			t.Fatalf("unexpected marshal error: %v", err)
 		}
@@ -78,3 +88,189 @@ func Test_marshal(t *testing.T) {
 
 	}
 }
+
+func Test_encoderTimeOptions(t *testing.T) {
+	moscow := time.FixedZone("MSK", 3*60*60)
+	tm := time.Date(2013, time.December, 9, 21, 0, 12, 345678000, time.UTC)
+
+	tests := []struct {
+		enc   *Encoder
+		value interface{}
+		xml   string
+	}{
+		{&Encoder{}, tm, "<value><dateTime.iso8601>20131209T21:00:12</dateTime.iso8601></value>"},
+		{&Encoder{TimeLayout: "20060102T15:04:05Z07:00"}, tm.In(moscow), "<value><dateTime.iso8601>20131210T00:00:12+03:00</dateTime.iso8601></value>"},
+		{&Encoder{TimeLayout: "20060102T15:04:05.000000"}, tm, "<value><dateTime.iso8601>20131209T21:00:12.345678</dateTime.iso8601></value>"},
+		{&Encoder{TimeLocation: moscow}, tm, "<value><dateTime.iso8601>20131210T00:00:12</dateTime.iso8601></value>"},
+		{&Encoder{}, &struct {
+			Date time.Time `xmlrpc:"date,format=2006-01-02"`
+		}{tm}, "<value><struct><member><name>date</name><value><dateTime.iso8601>2013-12-09</dateTime.iso8601></value></member></struct></value>"},
+	}
+
+	for _, tt := range tests {
+		b, err := tt.enc.Marshal(tt.value)
+		if err != nil {
+			t.Fatalf("unexpected marshal error: %v", err)
+		}
+
+		if string(b) != tt.xml {
+			t.Fatalf("marshal error:\nexpected: %s\n     got: %s", tt.xml, string(b))
+		}
+	}
+}
+
+type vendorRecord struct {
+	ID      int64     `xmlrpc:"id,string"`
+	Ratio   float64   `xmlrpc:"ratio,string"`
+	Enabled bool      `xmlrpc:"enabled,string"`
+	Note    string    `xmlrpc:"note,base64"`
+	Data    []byte    `xmlrpc:"data,base64"`
+	Created time.Time `xmlrpc:"created,unix"`
+	Updated time.Time `xmlrpc:"updated,unix,i8"`
+	Size    int       `xmlrpc:"size,i8"`
+}
+
+const vendorRecordXML = "<value><struct>" +
+	"<member><name>id</name><value><string>9007199254740993</string></value></member>" +
+	"<member><name>ratio</name><value><string>0.5</string></value></member>" +
+	"<member><name>enabled</name><value><string>true</string></value></member>" +
+	"<member><name>note</name><value><base64>aGVsbG8=</base64></value></member>" +
+	"<member><name>data</name><value><base64>AQI=</base64></value></member>" +
+	"<member><name>created</name><value><int>1386622812</int></value></member>" +
+	"<member><name>updated</name><value><i8>1386622812</i8></value></member>" +
+	"<member><name>size</name><value><i8>10</i8></value></member>" +
+	"</struct></value>"
+
+func Test_marshalWireTypeOptions(t *testing.T) {
+	tm := time.Unix(1386622812, 0).UTC()
+	v := vendorRecord{9007199254740993, 0.5, true, "hello", []byte{1, 2}, tm, tm, 10}
+
+	b, err := marshal(v)
+	if err != nil {
+		t.Fatalf("unexpected marshal error: %v", err)
+	}
+	if string(b) != vendorRecordXML {
+		t.Fatalf("marshal error:\nexpected: %s\n     got: %s", vendorRecordXML, string(b))
+	}
+
+	var decoded vendorRecord
+	if err := unmarshal(b, &decoded); err != nil {
+		t.Fatalf("unexpected unmarshal error: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, v) {
+		t.Fatalf("unmarshal error:\nexpected: %+v\n     got: %+v", v, decoded)
+	}
+}
+
+func Test_marshalEscapesMemberNames(t *testing.T) {
+	b, err := marshal(map[string]int{"a<b>&c": 1})
+	if err != nil {
+		t.Fatalf("unexpected marshal error: %v", err)
+	}
+
+	expected := "<value><struct><member><name>a&lt;b&gt;&amp;c</name><value><int>1</int></value></member></struct></value>"
+	if string(b) != expected {
+		t.Fatalf("marshal error:\nexpected: %s\n     got: %s", expected, string(b))
+	}
+
+	var v map[string]int
+	if err := unmarshal(b, &v); err != nil {
+		t.Fatalf("unexpected unmarshal error: %v", err)
+	}
+	if v["a<b>&c"] != 1 {
+		t.Fatalf("unexpected result: %v", v)
+	}
+
+	if _, err := marshal(map[string]int{"\x01": 1}); err == nil {
+		t.Fatal("expected error for invalid member name, but didn't get it")
+	} else if _, ok := err.(EncodeError); !ok {
+		t.Fatalf("expected EncodeError, got %T", err)
+	}
+}
+
+type node struct {
+	Name     string  `xmlrpc:"name"`
+	Children []*node `xmlrpc:"children"`
+}
+
+func Test_marshalCycles(t *testing.T) {
+	root := &node{Name: "root"}
+	child := &node{Name: "child"}
+	root.Children = []*node{child, child}
+
+	// shared values are not cycles
+	if _, err := marshal(root); err != nil {
+		t.Fatalf("unexpected marshal error: %v", err)
+	}
+
+	child.Children = []*node{root}
+
+	m := map[string]interface{}{"name": "map"}
+	m["self"] = m
+
+	s := []interface{}{1, nil}
+	s[1] = s
+
+	tests := []struct {
+		value interface{}
+		path  string
+	}{
+		{root, "children[0].children[0]"},
+		{m, "self"},
+		{s, "[1]"},
+	}
+
+	for _, tt := range tests {
+		_, err := marshal(tt.value)
+		eerr, ok := err.(EncodeError)
+		if !ok {
+			t.Fatalf("expected EncodeError, got %v", err)
+		}
+		if eerr.Path != tt.path {
+			t.Fatalf("expected error at %q, got %q", tt.path, eerr.Path)
+		}
+	}
+
+	_, err := EncodeMethodCall("tree.save", 1, root)
+	if eerr, ok := err.(EncodeError); !ok || eerr.Path != "params[1].children[0].children[0]" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func Test_marshalMaxDepth(t *testing.T) {
+	enc := &Encoder{MaxDepth: 3}
+
+	if _, err := enc.Marshal([][]int{{1}}); err != nil {
+		t.Fatalf("unexpected marshal error: %v", err)
+	}
+
+	_, err := enc.Marshal([][][]int{{{1}}})
+	if eerr, ok := err.(EncodeError); !ok || eerr.Path != "[0][0][0]" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func Test_marshalOrderedMap(t *testing.T) {
+	m := NewOrderedMap("zeta", 1, "alpha", nil)
+	m.Set("mid", map[string]int{"b": 2, "a": 1})
+	m.Set("zeta", 3)
+
+	enc := &Encoder{MapOrder: MapOrderSorted}
+	b, err := enc.EncodeMethodCall("report.columns", m)
+	if err != nil {
+		t.Fatalf("unexpected encode error: %v", err)
+	}
+
+	expected := `<?xml version="1.0" encoding="UTF-8"?><methodCall><methodName>report.columns</methodName><params><param>` +
+		"<value><struct><member><name>zeta</name><value><int>3</int></value></member><member><name>alpha</name><value/></member>" +
+		"<member><name>mid</name><value><struct><member><name>a</name><value><int>1</int></value></member><member><name>b</name><value><int>2</int></value></member></struct></value></member></struct></value>" +
+		"</param></params></methodCall>"
+	if string(b) != expected {
+		t.Fatalf("encode error:\nexpected: %s\n     got: %s", expected, string(b))
+	}
+
+	m.Delete("zeta")
+	if keys := m.Keys(); !reflect.DeepEqual(keys, []string{"alpha", "mid"}) {
+		t.Fatalf("unexpected keys: %v", keys)
+	}
+}
@@ -0,0 +1,96 @@
+package xmlrpc
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+)
+
+// TransportError is returned when a method call fails to reach the server or
+// its response can't be read, e.g. because of network timeout.
+type TransportError struct {
+	Err error
+}
+
+func (e TransportError) Error() string { return e.Err.Error() }
+
+func (e TransportError) Unwrap() error { return e.Err }
+
+// HTTPError is returned when the server responds with non 2xx status code.
+type HTTPError struct {
+	StatusCode int
+}
+
+func (e HTTPError) Error() string {
+	return fmt.Sprintf("request error: bad status code - %d", e.StatusCode)
+}
+
+// DecodeError is returned when a response can't be decoded, e.g. because it
+// isn't valid XML-RPC, exceeds Limits or doesn't match type of reply.
+type DecodeError struct {
+	Err error
+}
+
+func (e DecodeError) Error() string { return e.Err.Error() }
+
+func (e DecodeError) Unwrap() error { return e.Err }
+
+// IsTimeout reports whether err is caused by timeout, including deadline of
+// context passed to CallContext.
+func IsTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// IsTemporary reports whether err is a transport failure or HTTP status, which
+// is likely to go away when the call is retried.
+func IsTemporary(err error) bool {
+	if IsTimeout(err) {
+		return true
+	}
+
+	var httpErr HTTPError
+	if errors.As(err, &httpErr) {
+		switch httpErr.StatusCode {
+		case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+
+	var transportErr TransportError
+	if errors.As(err, &transportErr) {
+		var netErr interface{ Temporary() bool }
+		if errors.As(err, &netErr) {
+			return netErr.Temporary()
+		}
+		return errors.Is(err, io.ErrUnexpectedEOF)
+	}
+
+	return false
+}
+
+// IsDecodeError reports whether err is a DecodeError.
+func IsDecodeError(err error) bool {
+	var decodeErr DecodeError
+	return errors.As(err, &decodeErr)
+}
+
+// readError classifies err of reading response body.
+func readError(err error) error {
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return TransportError{Err: err}
+	}
+	return DecodeError{Err: err}
+}
+
+// decodeError wraps err, if it isn't FaultError, into DecodeError.
+func decodeError(err error) error {
+	if _, ok := err.(FaultError); ok {
+		return err
+	}
+	return DecodeError{Err: err}
+}
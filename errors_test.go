@@ -0,0 +1,131 @@
+package xmlrpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func Test_clientErrorTypes(t *testing.T) {
+	s := newTestServer()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/unavailable":
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case "/slow":
+			time.Sleep(100 * time.Millisecond)
+			io.WriteString(w, booksRespXML)
+		default:
+			s.ServeHTTP(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	newClient := func(path string, transport http.RoundTripper) *Client {
+		client, err := NewClient(ts.URL+path, transport)
+		if err != nil {
+			t.Fatalf("Can't create client: %v", err)
+		}
+		return client
+	}
+
+	client := newClient("/", userTransport{})
+	defer client.Close()
+
+	err := client.Call("library.remove", nil, nil)
+	var fault FaultError
+	if !errors.As(err, &fault) || !IsMethodNotFound(err) {
+		t.Fatalf("expected method not found fault, got %#v", err)
+	}
+
+	var n int
+	err = client.Call("library.whoami", nil, &n)
+	if !IsDecodeError(err) {
+		t.Fatalf("expected decode error, got %#v", err)
+	}
+	var mismatch TypeMismatchError
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("expected TypeMismatchError, got %#v", err)
+	}
+
+	client = newClient("/unavailable", nil)
+	defer client.Close()
+
+	err = client.Call("library.whoami", nil, nil)
+	if !errors.Is(err, HTTPError{StatusCode: http.StatusServiceUnavailable}) || !IsTemporary(err) {
+		t.Fatalf("expected temporary HTTP error, got %#v", err)
+	}
+	if err.Error() != "request error: bad status code - 503" {
+		t.Fatalf("unexpected error message: %v", err)
+	}
+
+	client = newClient("/slow", &http.Transport{ResponseHeaderTimeout: 10 * time.Millisecond})
+	defer client.Close()
+
+	err = client.Call("library.whoami", nil, nil)
+	var transportErr TransportError
+	if !errors.As(err, &transportErr) || !IsTimeout(err) || !IsTemporary(err) {
+		t.Fatalf("expected transport timeout error, got %#v", err)
+	}
+
+	client = newClient("/slow", nil)
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	err = client.CallContext(ctx, "library.whoami", nil, nil)
+	if !errors.Is(err, context.DeadlineExceeded) || !IsTimeout(err) {
+		t.Fatalf("expected deadline exceeded error, got %#v", err)
+	}
+}
+
+func Test_errorPredicates(t *testing.T) {
+	if IsTimeout(errors.New("timeout")) || IsTemporary(errors.New("timeout")) || IsDecodeError(errors.New("timeout")) {
+		t.Fatal("unexpected match of plain error")
+	}
+	if IsTemporary(HTTPError{StatusCode: http.StatusNotFound}) {
+		t.Fatal("IsTemporary: expected false for status 404")
+	}
+	if !IsTemporary(TransportError{Err: io.ErrUnexpectedEOF}) {
+		t.Fatal("IsTemporary: expected true for unexpected EOF")
+	}
+}
+
+func Test_clientFaultDecoder(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, `<methodResponse><fault><value><struct>
+			<member><name>faultCode</name><value><string>4</string></value></member>
+			<member><name>faultString</name><value>Too many parameters</value></member>
+		</struct></value></fault></methodResponse>`)
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		dec      *Decoder
+		expected func(err error) bool
+	}{
+		{nil, IsDecodeError},
+		{&Decoder{CoerceTypes: true}, func(err error) bool { return IsFault(err, 4) }},
+		{&Decoder{CoerceTypes: true, Limits: Limits{MaxStringLength: 3}}, func(err error) bool {
+			var limitErr LimitError
+			return errors.As(err, &limitErr)
+		}},
+	}
+
+	for _, tt := range tests {
+		client, err := NewClientWithOptions(ts.URL, nil, &ClientOptions{Decoder: tt.dec})
+		if err != nil {
+			t.Fatalf("Can't create client: %v", err)
+		}
+		defer client.Close()
+
+		if err = client.Call("library.whoami", nil, nil); !tt.expected(err) {
+			t.Fatalf("unexpected error for %+v: %#v", tt.dec, err)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+package xmlrpc
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Fault codes defined by specification for fault code interoperability,
+// which is followed by many XML-RPC servers.
+const (
+	// FaultParseError means that XML isn't well formed.
+	FaultParseError = -32700
+	// FaultUnsupportedEncoding means that XML is in unsupported encoding.
+	FaultUnsupportedEncoding = -32701
+	// FaultInvalidCharacter means that XML has invalid character for its
+	// encoding.
+	FaultInvalidCharacter = -32702
+	// FaultInvalidRequest means that XML isn't valid XML-RPC.
+	FaultInvalidRequest = -32600
+	// FaultMethodNotFound means that requested method doesn't exist.
+	FaultMethodNotFound = -32601
+	// FaultInvalidParams means that params don't match the method.
+	FaultInvalidParams = -32602
+	// FaultInternalError means that server failed to handle the call.
+	FaultInternalError = -32603
+	// FaultApplicationError is the code of errors of the called method.
+	FaultApplicationError = -32500
+	// FaultSystemError is the code of errors of the server system.
+	FaultSystemError = -32400
+	// FaultTransportError is the code of errors of the server transport.
+	FaultTransportError = -32300
+)
+
+// faultCode returns code of FaultError found in err chain.
+func faultCode(err error) (int, bool) {
+	var fault FaultError
+	if errors.As(err, &fault) {
+		return fault.Code, true
+	}
+	return 0, false
+}
+
+// IsFault reports whether err is a FaultError with code.
+func IsFault(err error, code int) bool {
+	c, ok := faultCode(err)
+	return ok && c == code
+}
+
+// IsParseError reports whether err is a fault meaning that XML isn't well
+// formed, is in unsupported encoding or has invalid characters.
+func IsParseError(err error) bool {
+	c, ok := faultCode(err)
+	return ok && (c == FaultParseError || c == FaultUnsupportedEncoding || c == FaultInvalidCharacter)
+}
+
+// IsInvalidRequest reports whether err is a fault meaning that request isn't
+// valid XML-RPC.
+func IsInvalidRequest(err error) bool {
+	return IsFault(err, FaultInvalidRequest)
+}
+
+// IsMethodNotFound reports whether err is a fault meaning that requested
+// method doesn't exist.
+func IsMethodNotFound(err error) bool {
+	return IsFault(err, FaultMethodNotFound)
+}
+
+// IsInvalidParams reports whether err is a fault meaning that params don't
+// match the method.
+func IsInvalidParams(err error) bool {
+	return IsFault(err, FaultInvalidParams)
+}
+
+// IsInternalError reports whether err is a fault meaning that server failed
+// to handle the call.
+func IsInternalError(err error) bool {
+	return IsFault(err, FaultInternalError)
+}
+
+// parseFault converts error of reading or parsing a method call to fault.
+func parseFault(err error) FaultError {
+	var charsetErr unsupportedCharsetError
+	var syntaxErr *xml.SyntaxError
+
+	switch {
+	case errors.As(err, &charsetErr):
+		return FaultError{Code: FaultUnsupportedEncoding, String: fmt.Sprintf("parse error: %v", err)}
+	case errors.As(err, &syntaxErr) && strings.Contains(syntaxErr.Msg, "invalid UTF-8"):
+		return FaultError{Code: FaultInvalidCharacter, String: fmt.Sprintf("parse error: %v", err)}
+	case errors.As(err, &syntaxErr), errors.Is(err, io.ErrUnexpectedEOF), errors.Is(err, io.EOF):
+		return FaultError{Code: FaultParseError, String: fmt.Sprintf("parse error: %v", err)}
+	default:
+		return FaultError{Code: FaultInvalidRequest, String: fmt.Sprintf("invalid request: %v", err)}
+	}
+}
@@ -0,0 +1,61 @@
+package xmlrpc
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func Test_faultPredicates(t *testing.T) {
+	notFound := fmt.Errorf("call failed: %w", FaultError{Code: FaultMethodNotFound, String: "no such method"})
+
+	if !IsMethodNotFound(notFound) {
+		t.Fatal("IsMethodNotFound: expected true for wrapped fault")
+	}
+	if IsParseError(notFound) || IsInvalidParams(notFound) || IsInternalError(notFound) || IsInvalidRequest(notFound) {
+		t.Fatal("unexpected match of method not found fault")
+	}
+	if IsMethodNotFound(fmt.Errorf("no such method")) {
+		t.Fatal("IsMethodNotFound: expected false for non fault error")
+	}
+
+	for _, code := range []int{FaultParseError, FaultUnsupportedEncoding, FaultInvalidCharacter} {
+		if !IsParseError(FaultError{Code: code}) {
+			t.Fatalf("IsParseError: expected true for code %d", code)
+		}
+	}
+
+	if !IsFault(FaultError{Code: 410}, 410) {
+		t.Fatal("IsFault: expected true for code 410")
+	}
+}
+
+func Test_serverParseFaults(t *testing.T) {
+	tests := []struct {
+		body        string
+		contentType string
+		code        int
+	}{
+		{"<methodCall><methodName>", "text/xml", FaultParseError},
+		{"<methodCall><methodName>a</methodName><params>", "text/xml", FaultParseError},
+		{"<methodCall><methodName>a</methodName></methodCall>", "text/xml; charset=klingon", FaultUnsupportedEncoding},
+		{"<methodCall><methodName>\xff</methodName></methodCall>", "text/xml", FaultInvalidCharacter},
+		{"<methodResponse><params></params></methodResponse>", "text/xml", FaultInvalidRequest},
+		{"<methodCall><params></params></methodCall>", "text/xml", FaultInvalidRequest},
+	}
+
+	s := NewServer()
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", "/", strings.NewReader(tt.body))
+		req.Header.Set("Content-Type", tt.contentType)
+
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, req)
+
+		err := Response(w.Body.Bytes()).Err()
+		if !IsFault(err, tt.code) {
+			t.Errorf("%q: expected fault %d, got %v", tt.body, tt.code, err)
+		}
+	}
+}
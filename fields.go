@@ -0,0 +1,240 @@
+package xmlrpc
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// field describes how a struct field is represented as a member of XML-RPC
+// struct.
+type field struct {
+	// name is the member name.
+	name string
+
+	// tagged reports that name comes from the field's xmlrpc tag.
+	tagged bool
+
+	// index is the sequence of field indexes leading to the field through
+	// embedded structs.
+	index []int
+
+	// typ is the type of the field, or the dereferenced type of an embedded
+	// struct pointer.
+	typ reflect.Type
+
+	omitEmpty bool
+	required  bool
+
+	// opts holds all options of the field's xmlrpc tag.
+	opts tagOptions
+}
+
+// structFields holds fields of a struct type in declaration order.
+type structFields struct {
+	list   []field
+	byName map[string]*field
+
+	// hasRequired reports that some of fields are tagged as required.
+	hasRequired bool
+}
+
+// fieldCache maps reflect.Type of a struct to its *structFields.
+var fieldCache sync.Map
+
+// cachedTypeFields returns fields of struct type t. Fields are computed once
+// per type and shared by encoder and decoder.
+func cachedTypeFields(t reflect.Type) *structFields {
+	if f, ok := fieldCache.Load(t); ok {
+		return f.(*structFields)
+	}
+
+	f, _ := fieldCache.LoadOrStore(t, typeFields(t))
+	return f.(*structFields)
+}
+
+// typeFields returns fields of struct type t, that become XML-RPC struct
+// members. Fields of anonymous structs, and of structs tagged with "inline"
+// option, are promoted to t following the rules of encoding/json: among fields
+// with the same name the least nested one wins, then the tagged one. If that
+// leaves more than one field, all of them are dropped.
+func typeFields(t reflect.Type) *structFields {
+	var current []field
+	next := []field{{typ: t}}
+
+	// count and nextCount hold number of times a struct type is embedded on
+	// the current and next level.
+	var count, nextCount map[reflect.Type]int
+
+	visited := map[reflect.Type]bool{}
+
+	var fields []field
+
+	for len(next) > 0 {
+		current, next = next, current[:0]
+		count, nextCount = nextCount, map[reflect.Type]int{}
+
+		for _, f := range current {
+			if visited[f.typ] {
+				continue
+			}
+			visited[f.typ] = true
+
+			for i := 0; i < f.typ.NumField(); i++ {
+				sf := f.typ.Field(i)
+
+				ft := sf.Type
+				if ft.Name() == "" && ft.Kind() == reflect.Ptr {
+					ft = ft.Elem()
+				}
+
+				if sf.Anonymous {
+					// unexported embedded structs can still provide exported
+					// fields, unless they have to be allocated.
+					if sf.PkgPath != "" && (ft.Kind() != reflect.Struct || sf.Type.Kind() == reflect.Ptr) {
+						continue
+					}
+				} else if sf.PkgPath != "" {
+					// skip unexported fields.
+					continue
+				}
+
+				name, opts := parseTag(sf.Tag.Get("xmlrpc"))
+				// skip ignored fields.
+				if name == "-" {
+					continue
+				}
+
+				index := make([]int, len(f.index)+1)
+				copy(index, f.index)
+				index[len(f.index)] = i
+
+				inline := ft.Kind() == reflect.Struct && ft != timeType &&
+					(opts.Contains("inline") || (sf.Anonymous && name == ""))
+
+				if !inline {
+					tagged := name != ""
+					if name == "" {
+						name = sf.Name
+					}
+
+					fields = append(fields, field{
+						name:      name,
+						tagged:    tagged,
+						index:     index,
+						typ:       ft,
+						omitEmpty: opts.Contains("omitempty"),
+						required:  opts.Contains("required"),
+						opts:      opts,
+					})
+					if count[f.typ] > 1 {
+						// the struct is embedded more than once on this level,
+						// add a duplicate so that the field is dropped below.
+						fields = append(fields, fields[len(fields)-1])
+					}
+					continue
+				}
+
+				nextCount[ft]++
+				if nextCount[ft] == 1 {
+					next = append(next, field{name: ft.Name(), index: index, typ: ft})
+				}
+			}
+		}
+	}
+
+	sort.Slice(fields, func(i, j int) bool {
+		x := fields
+		if x[i].name != x[j].name {
+			return x[i].name < x[j].name
+		}
+		if len(x[i].index) != len(x[j].index) {
+			return len(x[i].index) < len(x[j].index)
+		}
+		if x[i].tagged != x[j].tagged {
+			return x[i].tagged
+		}
+		return indexLess(x[i].index, x[j].index)
+	})
+
+	// drop fields hidden by less nested or tagged fields with the same name.
+	out := fields[:0]
+	for advance, i := 0, 0; i < len(fields); i += advance {
+		fi := fields[i]
+		for advance = 1; i+advance < len(fields); advance++ {
+			if fields[i+advance].name != fi.name {
+				break
+			}
+		}
+		if advance == 1 {
+			out = append(out, fi)
+			continue
+		}
+		if dominant, ok := dominantField(fields[i : i+advance]); ok {
+			out = append(out, dominant)
+		}
+	}
+
+	fields = out
+	sort.Slice(fields, func(i, j int) bool { return indexLess(fields[i].index, fields[j].index) })
+
+	sf := &structFields{list: fields, byName: make(map[string]*field, len(fields))}
+	for i := range sf.list {
+		sf.byName[sf.list[i].name] = &sf.list[i]
+		sf.hasRequired = sf.hasRequired || sf.list[i].required
+	}
+
+	return sf
+}
+
+// dominantField returns the field that hides other fields with the same name.
+// Fields are sorted by depth and tagging, so only the first two need to be
+// compared.
+func dominantField(fields []field) (field, bool) {
+	if len(fields) > 1 && len(fields[0].index) == len(fields[1].index) && fields[0].tagged == fields[1].tagged {
+		return field{}, false
+	}
+	return fields[0], true
+}
+
+func indexLess(a, b []int) bool {
+	for k, x := range a {
+		if k >= len(b) {
+			return false
+		}
+		if x != b[k] {
+			return x < b[k]
+		}
+	}
+	return len(a) < len(b)
+}
+
+// fieldByIndex returns the field of struct v at index. It returns false if the
+// field is unreachable through a nil embedded pointer.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// fieldByIndexAlloc returns the field of struct v at index, allocating nil
+// embedded pointers on its path.
+func fieldByIndexAlloc(v reflect.Value, index []int) reflect.Value {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v
+}
@@ -0,0 +1,247 @@
+package xmlrpc
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"reflect"
+	"testing"
+)
+
+type benchRecord struct {
+	ID      int     `xmlrpc:"id"`
+	Name    string  `xmlrpc:"name"`
+	Email   string  `xmlrpc:"email,omitempty"`
+	Score   float64 `xmlrpc:"score"`
+	Active  bool    `xmlrpc:"active"`
+	Comment string  `xmlrpc:"-"`
+}
+
+func benchRecords(n int) []benchRecord {
+	records := make([]benchRecord, n)
+	for i := range records {
+		records[i] = benchRecord{ID: i, Name: "John Smith", Email: "john@example.com", Score: 12.5, Active: true}
+	}
+	return records
+}
+
+func Benchmark_marshalStructArray(b *testing.B) {
+	records := benchRecords(1000)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := marshal(records); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func Benchmark_unmarshalStructArray(b *testing.B) {
+	data := benchResponse(b, 1000)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		var records []benchRecord
+		if err := unmarshal(data, &records); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// benchResponse returns method response holding n records.
+func benchResponse(b *testing.B, n int) []byte {
+	data, err := marshal(benchRecords(n))
+	if err != nil {
+		b.Fatal(err)
+	}
+	return bytes.Join([][]byte{[]byte("<methodResponse><params><param>"), data, []byte("</param></params></methodResponse>")}, nil)
+}
+
+// Benchmark_tokenizeStructArray reads tokens of the response decoded by
+// Benchmark_unmarshalStructArray. encoding/xml allocates names and character
+// data of every element, which accounts for most allocations of decoding, so
+// the field cache is measured separately by Benchmark_structFieldLookup.
+func Benchmark_tokenizeStructArray(b *testing.B) {
+	data := benchResponse(b, 1000)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		dec := xml.NewDecoder(bytes.NewReader(data))
+		for {
+			_, err := dec.Token()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+// uncachedFields maps member names to fields of struct v, the way decoder
+// did for every struct value before fields were cached.
+func uncachedFields(v reflect.Value) map[string]reflect.Value {
+	t := v.Type()
+	fields := make(map[string]reflect.Value)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		fv := v.FieldByName(f.Name)
+		if !fv.CanSet() {
+			continue
+		}
+		name, _ := parseTag(f.Tag.Get("xmlrpc"))
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields[name] = fv
+	}
+	return fields
+}
+
+// Benchmark_structFieldLookup finds fields of a struct value for all its
+// members, as decoder does for every struct value, without and with the
+// field cache.
+func Benchmark_structFieldLookup(b *testing.B) {
+	var record benchRecord
+	v := reflect.ValueOf(&record).Elem()
+	members := []string{"id", "name", "email", "score", "active"}
+
+	b.Run("uncached", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			fields := uncachedFields(v)
+			for _, name := range members {
+				if !fields[name].IsValid() {
+					b.Fatalf("field %s not found", name)
+				}
+			}
+		}
+	})
+
+	b.Run("cached", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			fields := cachedTypeFields(v.Type())
+			for _, name := range members {
+				f, ok := fields.byName[name]
+				if !ok || !fieldByIndexAlloc(v, f.index).IsValid() {
+					b.Fatalf("field %s not found", name)
+				}
+			}
+		}
+	})
+}
+
+func Test_cachedTypeFields(t *testing.T) {
+	typ := reflect.TypeOf(benchRecord{})
+
+	fields := cachedTypeFields(typ)
+	if fields != cachedTypeFields(typ) {
+		t.Fatal("expected fields to be cached")
+	}
+
+	var names []string
+	for _, f := range fields.list {
+		names = append(names, f.name)
+	}
+	if expected := []string{"id", "name", "email", "score", "active"}; !reflect.DeepEqual(names, expected) {
+		t.Fatalf("unexpected fields:\nexpected: %v\n     got: %v", expected, names)
+	}
+
+	if f := fields.byName["email"]; f == nil || !f.omitEmpty {
+		t.Fatalf("expected email field to have omitempty option")
+	}
+}
+
+type auth struct {
+	User  string `xmlrpc:"user"`
+	Token string `xmlrpc:"token"`
+}
+
+type Paging struct {
+	Limit  int `xmlrpc:"limit"`
+	Offset int `xmlrpc:"offset"`
+}
+
+type Sorting struct {
+	Order  string `xmlrpc:"order"`
+	Offset string `xmlrpc:"offset"`
+}
+
+type searchRequest struct {
+	auth
+	*Paging
+	Query string `xmlrpc:"query"`
+	Token string `xmlrpc:"token"`
+}
+
+type conflictRequest struct {
+	Paging
+	Sorting
+}
+
+type inlineRequest struct {
+	Auth   auth   `xmlrpc:",inline"`
+	Paging Paging `xmlrpc:"paging"`
+}
+
+func Test_typeFieldsEmbedded(t *testing.T) {
+	tests := []struct {
+		value interface{}
+		names []string
+	}{
+		// own token field hides token of embedded auth.
+		{searchRequest{}, []string{"user", "limit", "offset", "query", "token"}},
+		// offset of Paging and Sorting conflict, so both are dropped.
+		{conflictRequest{}, []string{"limit", "order"}},
+		{inlineRequest{}, []string{"user", "token", "paging"}},
+	}
+
+	for _, tt := range tests {
+		var names []string
+		for _, f := range cachedTypeFields(reflect.TypeOf(tt.value)).list {
+			names = append(names, f.name)
+		}
+
+		if !reflect.DeepEqual(names, tt.names) {
+			t.Fatalf("unexpected fields of %T:\nexpected: %v\n     got: %v", tt.value, tt.names, names)
+		}
+	}
+}
+
+func Test_marshalEmbedded(t *testing.T) {
+	req := searchRequest{auth: auth{User: "joe"}, Query: "books"}
+
+	b, err := marshal(req)
+	if err != nil {
+		t.Fatalf("unexpected marshal error: %v", err)
+	}
+
+	expected := "<value><struct><member><name>user</name><value><string>joe</string></value></member><member><name>query</name><value><string>books</string></value></member><member><name>token</name><value><string></string></value></member></struct></value>"
+	if string(b) != expected {
+		t.Fatalf("marshal error:\nexpected: %s\n     got: %s", expected, string(b))
+	}
+}
+
+func Test_unmarshalEmbedded(t *testing.T) {
+	encoded := "<value><struct><member><name>user</name><value><string>joe</string></value></member><member><name>limit</name><value><int>10</int></value></member><member><name>token</name><value><string>secret</string></value></member></struct></value>"
+
+	var req searchRequest
+	if err := unmarshal([]byte(encoded), &req); err != nil {
+		t.Fatalf("unmarshal error: %v", err)
+	}
+
+	if req.User != "joe" || req.Token != "secret" || req.auth.Token != "" {
+		t.Fatalf("unexpected result: %+v", req)
+	}
+	if req.Paging == nil || req.Limit != 10 {
+		t.Fatalf("expected embedded pointer to be allocated: %+v", req.Paging)
+	}
+}
@@ -0,0 +1,68 @@
+package xmlrpc
+
+import (
+	"context"
+	"net/rpc"
+)
+
+// Invoker calls method of xmlrpc service and decodes its result into reply.
+type Invoker func(ctx context.Context, method string, args, reply interface{}) error
+
+// Interceptor runs around every call made by Client.Call,
+// Client.CallContext and Client.StreamArray. It receives method name,
+// arguments and reply as passed by caller and continues the call by calling
+// next, after which reply holds decoded result. Reply of StreamArray calls is
+// nil, as their result is decoded later. Interceptor can short-circuit the
+// call by returning without calling next, or call next several times to
+// retry it.
+type Interceptor func(ctx context.Context, method string, args, reply interface{}, next Invoker) error
+
+// chainInterceptors returns invoker running interceptors in order around
+// invoker, so the first interceptor is the outermost one.
+func chainInterceptors(interceptors []Interceptor, invoker Invoker) Invoker {
+	for i := len(interceptors) - 1; i >= 0; i-- {
+		interceptor, next := interceptors[i], invoker
+		invoker = func(ctx context.Context, method string, args, reply interface{}) error {
+			return interceptor(ctx, method, args, reply, next)
+		}
+	}
+	return invoker
+}
+
+// Call invokes the named method, waits for it to complete, and returns its
+// error status. Interceptors of the client are run around the call.
+func (client *Client) Call(serviceMethod string, args interface{}, reply interface{}) error {
+	return client.CallContext(context.Background(), serviceMethod, args, reply)
+}
+
+// CallContext works like Call, but returns ctx.Err() when ctx is done before
+// the call completes. The HTTP request of the call is aborted then.
+func (client *Client) CallContext(ctx context.Context, serviceMethod string, args interface{}, reply interface{}) error {
+	return client.invoker(ctx, serviceMethod, args, reply)
+}
+
+// invoke is the Invoker which calls method of xmlrpc service.
+func (client *Client) invoke(ctx context.Context, serviceMethod string, args interface{}, reply interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// err receives error of the call with its type, which rpc.Client
+	// turns into rpc.ServerError
+	var err error
+	call := client.Client.Go(serviceMethod, &callArgs{ctx: ctx, args: args, err: &err}, reply, make(chan *rpc.Call, 1))
+
+	select {
+	case <-call.Done:
+		if err == nil {
+			err = call.Error
+		}
+		if err != nil && ctx.Err() != nil {
+			// the call failed as it was aborted
+			return ctx.Err()
+		}
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,257 @@
+package xmlrpc
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func Test_clientInterceptors(t *testing.T) {
+	var requests int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		io.WriteString(w, booksRespXML)
+	}))
+	defer ts.Close()
+
+	var calls []string
+	logging := func(ctx context.Context, method string, args, reply interface{}, next Invoker) error {
+		calls = append(calls, "log:"+method)
+		err := next(ctx, method, args, reply)
+		if books, ok := reply.(*[]book); ok {
+			calls = append(calls, "log:"+(*books)[0].Title)
+		}
+		return err
+	}
+	cache := func(ctx context.Context, method string, args, reply interface{}, next Invoker) error {
+		calls = append(calls, "cache:"+method)
+		if method == "cached.books" {
+			*reply.(*[]book) = []book{{"Cached", 1}}
+			return nil
+		}
+		return next(ctx, method, args, reply)
+	}
+
+	client, err := NewClientWithOptions(ts.URL, nil, &ClientOptions{Interceptors: []Interceptor{logging, cache}})
+	if err != nil {
+		t.Fatalf("Can't create client: %v", err)
+	}
+	defer client.Close()
+
+	var books []book
+	if err := client.Call("export.books", nil, &books); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := client.CallContext(context.Background(), "cached.books", nil, &books); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []string{
+		"log:export.books", "cache:export.books", "log:War and Piece",
+		"log:cached.books", "cache:cached.books", "log:Cached",
+	}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Fatalf("unexpected calls:\nexpected: %v\n     got: %v", expected, calls)
+	}
+	if requests != 1 {
+		t.Fatalf("expected 1 request, got %d", requests)
+	}
+}
+
+func Test_clientInterceptorError(t *testing.T) {
+	errDenied := errors.New("denied")
+	deny := func(ctx context.Context, method string, args, reply interface{}, next Invoker) error {
+		return errDenied
+	}
+
+	client, err := NewClientWithOptions("http://localhost:1", nil, &ClientOptions{Interceptors: []Interceptor{deny}})
+	if err != nil {
+		t.Fatalf("Can't create client: %v", err)
+	}
+	defer client.Close()
+
+	if err := client.Call("export.books", nil, nil); err != errDenied {
+		t.Fatalf("expected %v, got %v", errDenied, err)
+	}
+}
+
+func Test_clientCallContextCanceled(t *testing.T) {
+	client, err := NewClient("http://localhost:1", nil)
+	if err != nil {
+		t.Fatalf("Can't create client: %v", err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := client.CallContext(ctx, "export.books", nil, nil); err != context.Canceled {
+		t.Fatalf("expected %v, got %v", context.Canceled, err)
+	}
+}
+
+func Test_clientStreamArrayInterceptors(t *testing.T) {
+	var requests int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		io.WriteString(w, booksRespXML)
+	}))
+	defer ts.Close()
+
+	var calls []string
+	retry := func(ctx context.Context, method string, args, reply interface{}, next Invoker) error {
+		calls = append(calls, method)
+		if reply != nil {
+			t.Errorf("unexpected reply: %v", reply)
+		}
+		if method == "admin.export" {
+			return errors.New("not allowed")
+		}
+		if err := next(ctx, method, args, reply); err != nil {
+			return next(ctx, method, args, reply)
+		}
+		return nil
+	}
+
+	client, err := NewClientWithOptions(ts.URL, nil, &ClientOptions{Interceptors: []Interceptor{retry}})
+	if err != nil {
+		t.Fatalf("Can't create client: %v", err)
+	}
+	defer client.Close()
+
+	arr, err := client.StreamArray("export.books", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer arr.Close()
+
+	var n int
+	for arr.Next() {
+		n++
+	}
+	if err := arr.Err(); err != nil || n != 3 {
+		t.Fatalf("unexpected result: %d elements, %v", n, err)
+	}
+
+	if _, err = client.StreamArray("admin.export", nil); err == nil || err.Error() != "not allowed" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if expected := []string{"export.books", "admin.export"}; !reflect.DeepEqual(calls, expected) {
+		t.Fatalf("unexpected calls:\nexpected: %v\n     got: %v", expected, calls)
+	}
+	if requests != 2 {
+		t.Fatalf("expected 2 requests, got %d", requests)
+	}
+}
+
+func Test_clientCallContextAbortsRequest(t *testing.T) {
+	aborted := make(chan struct{})
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// closed connection is noticed after the body is read
+		ioutil.ReadAll(r.Body)
+		select {
+		case <-r.Context().Done():
+			close(aborted)
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer ts.Close()
+
+	client, err := NewClient(ts.URL, nil)
+	if err != nil {
+		t.Fatalf("Can't create client: %v", err)
+	}
+	defer client.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if err := client.CallContext(ctx, "export.books", nil, nil); err != context.DeadlineExceeded {
+		t.Fatalf("expected %v, got %v", context.DeadlineExceeded, err)
+	}
+
+	select {
+	case <-aborted:
+	case <-time.After(time.Second):
+		t.Fatal("request isn't aborted")
+	}
+}
+
+func Test_clientStreamArrayContext(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, booksRespXML)
+	}))
+	defer ts.Close()
+
+	cache := func(ctx context.Context, method string, args, reply interface{}, next Invoker) error {
+		if method == "cached.books" {
+			return nil
+		}
+		return next(ctx, method, args, reply)
+	}
+
+	client, err := NewClientWithOptions(ts.URL, nil, &ClientOptions{Interceptors: []Interceptor{cache}})
+	if err != nil {
+		t.Fatalf("Can't create client: %v", err)
+	}
+	defer client.Close()
+
+	if arr, err := client.StreamArrayContext(context.Background(), "cached.books", nil); arr != nil || err == nil {
+		t.Fatalf("unexpected result: %v, %v", arr, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := client.StreamArrayContext(ctx, "export.books", nil); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected %v, got %v", context.Canceled, err)
+	}
+}
+
+func Test_clientCallContextMapOrder(t *testing.T) {
+	var body []byte
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ = ioutil.ReadAll(r.Body)
+		io.WriteString(w, booksRespXML)
+	}))
+	defer ts.Close()
+
+	client, err := NewClient(ts.URL, nil)
+	if err != nil {
+		t.Fatalf("Can't create client: %v", err)
+	}
+	defer client.Close()
+
+	args := map[string]int{"d": 4, "c": 3, "b": 2, "a": 1, "e": 5, "f": 6, "g": 7, "h": 8}
+	expected, err := (&Encoder{MapOrder: MapOrderSorted}).EncodeMethodCall("export.books", args)
+	if err != nil {
+		t.Fatalf("unexpected encode error: %v", err)
+	}
+
+	// maps are iterated in random order, so unsorted body differs sooner or
+	// later.
+	for i := 0; i < 10; i++ {
+		ctx := WithMapOrder(context.Background(), MapOrderSorted)
+		if err := client.CallContext(ctx, "export.books", args, nil); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if string(body) != string(expected) {
+			t.Fatalf("unexpected request:\nexpected: %s\n     got: %s", expected, body)
+		}
+	}
+
+	if client.codec.encoder.MapOrder != MapOrderRandom {
+		t.Fatal("client encoder is changed")
+	}
+}
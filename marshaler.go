@@ -0,0 +1,717 @@
+package xmlrpc
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Marshaler is implemented by types that encode themselves into XML-RPC
+// values without reflection, usually with code generated by
+// cmd/xmlrpc-codegen. It takes precedence over encoding.TextMarshaler.
+type Marshaler interface {
+	MarshalXMLRPC(e *ValueEncoder) error
+}
+
+// Unmarshaler is implemented by types that decode themselves from XML-RPC
+// values without reflection, usually with code generated by
+// cmd/xmlrpc-codegen. It takes precedence over encoding.TextUnmarshaler.
+type Unmarshaler interface {
+	UnmarshalXMLRPC(d *ValueDecoder) error
+}
+
+var (
+	marshalerType   = reflect.TypeOf((*Marshaler)(nil)).Elem()
+	unmarshalerType = reflect.TypeOf((*Unmarshaler)(nil)).Elem()
+)
+
+// marshaler returns val as Marshaler, if val or a pointer to it implements
+// the interface with a method of its own.
+func marshaler(val reflect.Value) (Marshaler, bool) {
+	if !val.IsValid() || !val.CanInterface() {
+		return nil, false
+	}
+
+	if val.Type().Implements(marshalerType) && !promoted(val.Type(), "MarshalXMLRPC") {
+		return val.Interface().(Marshaler), true
+	}
+
+	if val.CanAddr() && reflect.PtrTo(val.Type()).Implements(marshalerType) && !promoted(val.Type(), "MarshalXMLRPC") {
+		return val.Addr().Interface().(Marshaler), true
+	}
+
+	return nil, false
+}
+
+// unmarshaler returns a pointer to val as Unmarshaler, if it implements the
+// interface with a method of its own.
+func unmarshaler(val reflect.Value) (Unmarshaler, bool) {
+	if val.Kind() == reflect.Interface || !val.CanAddr() {
+		return nil, false
+	}
+
+	if reflect.PtrTo(val.Type()).Implements(unmarshalerType) && !promoted(val.Type(), "UnmarshalXMLRPC") {
+		return val.Addr().Interface().(Unmarshaler), true
+	}
+
+	return nil, false
+}
+
+// promotedCache holds results of promoted by method key.
+var promotedCache sync.Map
+
+type promotedKey struct {
+	typ  reflect.Type
+	name string
+}
+
+// promoted reports whether method name of t or *t is promoted from an
+// embedded field. Such method would encode or decode only the embedded
+// struct, so the outer struct is handled by reflection instead. Promoted
+// methods are recognized by their wrappers, which are generated by compiler.
+func promoted(t reflect.Type, name string) bool {
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+
+	key := promotedKey{t, name}
+	if p, ok := promotedCache.Load(key); ok {
+		return p.(bool)
+	}
+
+	embeds := false
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Anonymous {
+			embeds = true
+			break
+		}
+	}
+
+	p := false
+	if embeds {
+		// method of *t declared on t is a wrapper too
+		m, ok := t.MethodByName(name)
+		if !ok {
+			m, ok = reflect.PtrTo(t).MethodByName(name)
+		}
+		if f := runtime.FuncForPC(m.Func.Pointer()); ok && f != nil {
+			file, _ := f.FileLine(f.Entry())
+			p = file == "<autogenerated>"
+		}
+	}
+
+	promotedCache.Store(key, p)
+	return p
+}
+
+// ValueEncoder writes a single XML-RPC value for Marshaler. Scalars are
+// written with methods named after their Go types, structs are written
+// between BeginStruct and EndStruct with every member between BeginMember
+// and EndMember, and arrays are written between BeginArray and EndArray.
+// Values of other types are written with Encode, which follows the usual
+// encoding rules. The first error stops encoding and is returned by Err.
+type ValueEncoder struct {
+	enc *encoder
+	buf bytes.Buffer
+	err error
+
+	// arrays is the number of arrays being written.
+	arrays int
+}
+
+// check returns false if encoding has failed or a value written at the
+// current depth would exceed MaxDepth.
+func (e *ValueEncoder) check() bool {
+	if e.err != nil {
+		return false
+	}
+	if max := e.enc.opts.MaxDepth; max > 0 && e.enc.depth+1 > max {
+		e.err = e.enc.errorf("maximum depth of %d exceeded", max)
+		return false
+	}
+	return true
+}
+
+// done moves path of array being written to its next element.
+func (e *ValueEncoder) done() {
+	if n := len(e.enc.path); e.arrays > 0 && n > 0 && e.enc.path[n-1].name == "" {
+		e.enc.path[n-1].index++
+	}
+}
+
+// scalar writes value of XML-RPC type typeName holding text s.
+func (e *ValueEncoder) scalar(typeName string, s string) {
+	if !e.check() {
+		return
+	}
+
+	e.buf.WriteString("<value><")
+	e.buf.WriteString(typeName)
+	e.buf.WriteByte('>')
+	e.buf.WriteString(s)
+	e.buf.WriteString("</")
+	e.buf.WriteString(typeName)
+	e.buf.WriteString("></value>")
+	e.done()
+}
+
+// String writes string value.
+func (e *ValueEncoder) String(s string) {
+	var buf bytes.Buffer
+	xml.Escape(&buf, []byte(s))
+	e.scalar("string", buf.String())
+}
+
+// Int writes int value.
+func (e *ValueEncoder) Int(i int) {
+	e.scalar("int", strconv.Itoa(i))
+}
+
+// Int64 writes int value.
+func (e *ValueEncoder) Int64(i int64) {
+	e.scalar("int", strconv.FormatInt(i, 10))
+}
+
+// Float64 writes double value.
+func (e *ValueEncoder) Float64(f float64) {
+	e.scalar("double", strconv.FormatFloat(f, 'f', -1, 64))
+}
+
+// Bool writes boolean value.
+func (e *ValueEncoder) Bool(b bool) {
+	if b {
+		e.scalar("boolean", "1")
+	} else {
+		e.scalar("boolean", "0")
+	}
+}
+
+// Time writes dateTime.iso8601 value formatted with Encoder options.
+func (e *ValueEncoder) Time(t time.Time) {
+	e.scalar("dateTime.iso8601", e.enc.formatTime(t, ""))
+}
+
+// BeginStruct starts struct value.
+func (e *ValueEncoder) BeginStruct() {
+	if !e.check() {
+		return
+	}
+	e.enc.depth++
+	e.buf.WriteString("<value><struct>")
+}
+
+// EndStruct ends struct value started by BeginStruct.
+func (e *ValueEncoder) EndStruct() {
+	if e.err != nil {
+		return
+	}
+	e.enc.depth--
+	e.buf.WriteString("</struct></value>")
+	e.done()
+}
+
+// BeginMember starts struct member with name. Its value must be written
+// before EndMember.
+func (e *ValueEncoder) BeginMember(name string) {
+	if e.err != nil {
+		return
+	}
+
+	escaped, err := escapeName(name)
+	if err != nil {
+		e.err = e.enc.pathError(err)
+		return
+	}
+
+	e.enc.path = append(e.enc.path, pathElem{name: name})
+	e.buf.WriteString("<member><name>")
+	e.buf.WriteString(escaped)
+	e.buf.WriteString("</name>")
+}
+
+// EndMember ends struct member started by BeginMember.
+func (e *ValueEncoder) EndMember() {
+	if e.err != nil {
+		return
+	}
+	e.enc.path = e.enc.path[:len(e.enc.path)-1]
+	e.buf.WriteString("</member>")
+}
+
+// BeginArray starts array value.
+func (e *ValueEncoder) BeginArray() {
+	if !e.check() {
+		return
+	}
+	e.enc.depth++
+	e.arrays++
+	e.enc.path = append(e.enc.path, pathElem{})
+	e.buf.WriteString("<value><array><data>")
+}
+
+// EndArray ends array value started by BeginArray.
+func (e *ValueEncoder) EndArray() {
+	if e.err != nil {
+		return
+	}
+	e.enc.depth--
+	e.arrays--
+	e.enc.path = e.enc.path[:len(e.enc.path)-1]
+	e.buf.WriteString("</data></array></value>")
+	e.done()
+}
+
+// Encode writes v following the usual encoding rules.
+func (e *ValueEncoder) Encode(v interface{}) {
+	e.EncodeTagged(v, "")
+}
+
+// EncodeTagged writes v like Encode, applying options of xmlrpc field tag,
+// e.g. "string" or "format=2006-01-02".
+func (e *ValueEncoder) EncodeTagged(v interface{}, opts string) {
+	if e.err != nil {
+		return
+	}
+
+	if v == nil {
+		e.buf.WriteString("<value/>")
+		e.done()
+		return
+	}
+
+	b, err := e.enc.encodeValue(reflect.ValueOf(v), tagOptions(opts))
+	if err != nil {
+		e.err = err
+		return
+	}
+
+	e.buf.Write(b)
+	e.done()
+}
+
+// Err returns the first error occurred while writing the value.
+func (e *ValueEncoder) Err() error {
+	return e.err
+}
+
+// encodeMarshaler encodes the current value with its Marshaler m.
+func (enc *encoder) encodeMarshaler(m Marshaler) ([]byte, error) {
+	e := &ValueEncoder{enc: enc}
+
+	// the value is counted again by ValueEncoder
+	enc.depth--
+	path := len(enc.path)
+	err := m.MarshalXMLRPC(e)
+	enc.depth++
+	enc.path = enc.path[:path]
+
+	if err == nil {
+		err = e.err
+	}
+	if err != nil {
+		return nil, enc.pathError(err)
+	}
+
+	return e.buf.Bytes(), nil
+}
+
+// ValueDecoder reads a single XML-RPC value for Unmarshaler. Scalars are read
+// with methods named after their Go types, struct members are read with
+// Struct and array elements with Array. Values of other types are read with
+// Decode, which follows the usual decoding rules. Decoder options and limits
+// apply to all of them.
+type ValueDecoder struct {
+	dec *decoder
+
+	// typ is the type of the Unmarshaler, reported in errors.
+	typ reflect.Type
+
+	// empty reports that the struct value read by Struct is empty.
+	empty bool
+}
+
+// start reads tokens up to the type element of the value and returns its
+// name. It returns empty name for empty values and text of values without
+// type element.
+func (d *ValueDecoder) start() (typeName string, text []byte, err error) {
+	for {
+		tok, err := d.dec.Token()
+		if err != nil {
+			return "", nil, err
+		}
+
+		switch t := tok.(type) {
+		case xml.EndElement:
+			if t.Name.Local != "value" {
+				return "", nil, invalidXmlError
+			}
+			d.dec.valueClosed = true
+			return "", nil, nil
+		case xml.StartElement:
+			return t.Name.Local, nil, nil
+		case xml.CharData:
+			if text := bytes.TrimSpace(t); len(text) > 0 {
+				return "string", append([]byte(nil), text...), nil
+			}
+		}
+	}
+}
+
+// scalar reads scalar value and returns its type name and data. It returns
+// empty type name for empty values.
+func (d *ValueDecoder) scalar() (typeName string, data []byte, err error) {
+	typeName, text, err := d.start()
+	if err != nil || typeName == "" || text != nil {
+		return typeName, text, err
+	}
+
+	if typeName == "struct" || typeName == "array" {
+		return "", nil, d.mismatch(typeName)
+	}
+
+	tok, err := d.dec.Token()
+	if err != nil {
+		return "", nil, err
+	}
+
+	switch t := tok.(type) {
+	case xml.EndElement:
+		// empty value of the type is decoded as zero value
+		return "", nil, nil
+	case xml.CharData:
+		data = []byte(t.Copy())
+	default:
+		return "", nil, invalidXmlError
+	}
+
+	// </type>
+	return typeName, data, d.dec.Skip()
+}
+
+// mismatch returns TypeMismatchError for value of XML-RPC type typeName.
+func (d *ValueDecoder) mismatch(typeName string) error {
+	return TypeMismatchError(fmt.Sprintf("error: type mismatch - can't unmarshal %s to %v", typeName, d.typ))
+}
+
+// String reads string value.
+func (d *ValueDecoder) String() (s string, err error) {
+	typeName, data, err := d.scalar()
+	if err != nil || typeName == "" {
+		return "", err
+	}
+
+	if typeName == "string" {
+		if err = checkLimit("MaxStringLength", len(data), d.dec.opts.Limits.MaxStringLength); err != nil {
+			return "", err
+		}
+		return string(data), nil
+	}
+
+	err = d.dec.decodeScalar(reflect.ValueOf(&s).Elem(), typeName, data, "")
+	return s, err
+}
+
+// Int reads int value.
+func (d *ValueDecoder) Int() (i int, err error) {
+	typeName, data, err := d.scalar()
+	if err != nil || typeName == "" {
+		return 0, err
+	}
+
+	if typeName == "int" || typeName == "i4" || typeName == "i8" {
+		n, err := strconv.ParseInt(string(data), 10, strconv.IntSize)
+		return int(n), err
+	}
+
+	err = d.dec.decodeScalar(reflect.ValueOf(&i).Elem(), typeName, data, "")
+	return i, err
+}
+
+// Int64 reads int value.
+func (d *ValueDecoder) Int64() (i int64, err error) {
+	typeName, data, err := d.scalar()
+	if err != nil || typeName == "" {
+		return 0, err
+	}
+
+	if typeName == "int" || typeName == "i4" || typeName == "i8" {
+		return strconv.ParseInt(string(data), 10, 64)
+	}
+
+	err = d.dec.decodeScalar(reflect.ValueOf(&i).Elem(), typeName, data, "")
+	return i, err
+}
+
+// Float64 reads double value.
+func (d *ValueDecoder) Float64() (f float64, err error) {
+	typeName, data, err := d.scalar()
+	if err != nil || typeName == "" {
+		return 0, err
+	}
+
+	if typeName == "double" {
+		return strconv.ParseFloat(string(data), 64)
+	}
+
+	err = d.dec.decodeScalar(reflect.ValueOf(&f).Elem(), typeName, data, "")
+	return f, err
+}
+
+// Bool reads boolean value.
+func (d *ValueDecoder) Bool() (b bool, err error) {
+	typeName, data, err := d.scalar()
+	if err != nil || typeName == "" {
+		return false, err
+	}
+
+	if typeName == "boolean" {
+		return strconv.ParseBool(string(data))
+	}
+
+	err = d.dec.decodeScalar(reflect.ValueOf(&b).Elem(), typeName, data, "")
+	return b, err
+}
+
+// Time reads dateTime.iso8601 value, parsed with Decoder options.
+func (d *ValueDecoder) Time() (t time.Time, err error) {
+	typeName, data, err := d.scalar()
+	if err != nil || typeName == "" {
+		return time.Time{}, err
+	}
+
+	if typeName == "dateTime.iso8601" {
+		return d.dec.parseTime(string(data), "")
+	}
+
+	err = d.dec.decodeScalar(reflect.ValueOf(&t).Elem(), typeName, data, "")
+	return t, err
+}
+
+// Struct reads struct value, calling fn for each member with its name. fn
+// decodes the member value with methods of d and returns true, or returns
+// false without reading anything to skip unknown member.
+func (d *ValueDecoder) Struct(fn func(name string) (bool, error)) error {
+	typeName, text, err := d.start()
+
+	// set once members, which may be structs read by Struct, are read
+	empty := err == nil && typeName == ""
+	defer func() { d.empty = empty }()
+
+	if err != nil || typeName == "" {
+		return err
+	}
+	if typeName != "struct" || text != nil {
+		return d.mismatch(typeName)
+	}
+
+	dec := d.dec
+
+	var seen map[string]bool
+	if dec.opts.DuplicateMembers != DuplicateLastWins {
+		seen = make(map[string]bool)
+	}
+
+	var unknown []string
+	var members int
+	for {
+		tok, err := dec.nextElement()
+		if err != nil {
+			return err
+		}
+
+		t, ok := tok.(xml.StartElement)
+		if !ok {
+			// </struct>
+			break
+		}
+		if t.Name.Local != "member" {
+			return invalidXmlError
+		}
+
+		members++
+		if err = checkLimit("MaxStructMembers", members, dec.opts.Limits.MaxStructMembers); err != nil {
+			return err
+		}
+
+		tagName, name, err := dec.readTag()
+		if err != nil {
+			return err
+		}
+		if tagName != "name" {
+			return invalidXmlError
+		}
+
+		skip := false
+		if seen[string(name)] {
+			switch dec.opts.DuplicateMembers {
+			case DuplicateError:
+				return DuplicateMemberError{Type: d.typ, Name: string(name)}
+			case DuplicateFirstWins:
+				skip = true
+			}
+		}
+		if seen != nil {
+			seen[string(name)] = true
+		}
+
+		if _, err = dec.findStart("value"); err != nil {
+			return err
+		}
+
+		known := false
+		if !skip {
+			if known, err = d.nested(func() (bool, error) { return fn(string(name)) }); err != nil {
+				return err
+			}
+			if !known && dec.opts.DisallowUnknownMembers {
+				unknown = append(unknown, string(name))
+			}
+		}
+		if !known {
+			if err = d.skip(); err != nil {
+				return err
+			}
+		}
+
+		if err = dec.skipValueEnd(); err != nil {
+			return err
+		}
+		// </member>
+		if err = dec.Skip(); err != nil {
+			return err
+		}
+	}
+
+	if len(unknown) > 0 {
+		return UnknownMembersError{Type: d.typ, Members: unknown}
+	}
+
+	return nil
+}
+
+// Array reads array value, calling fn for each element with its index. fn
+// decodes the element with methods of d.
+func (d *ValueDecoder) Array(fn func(i int) error) error {
+	typeName, text, err := d.start()
+	if err != nil || typeName == "" {
+		return err
+	}
+	if typeName != "array" || text != nil {
+		return d.mismatch(typeName)
+	}
+
+	dec := d.dec
+
+	tok, err := dec.nextElement()
+	if err != nil {
+		return err
+	}
+
+	t, ok := tok.(xml.StartElement)
+	if !ok {
+		// </array>
+		return nil
+	}
+	if t.Name.Local != "data" {
+		return invalidXmlError
+	}
+
+	for i := 0; ; i++ {
+		tok, err := dec.nextElement()
+		if err != nil {
+			return err
+		}
+
+		t, ok := tok.(xml.StartElement)
+		if !ok {
+			// </data>
+			break
+		}
+		if t.Name.Local != "value" {
+			return invalidXmlError
+		}
+
+		if err = checkLimit("MaxArrayLength", i+1, dec.opts.Limits.MaxArrayLength); err != nil {
+			return err
+		}
+
+		if _, err = d.nested(func() (bool, error) { return true, fn(i) }); err != nil {
+			return err
+		}
+		if err = dec.skipValueEnd(); err != nil {
+			return err
+		}
+	}
+
+	// </array>
+	return dec.Skip()
+}
+
+// nested calls fn decoding a value nested in the current one.
+func (d *ValueDecoder) nested(fn func() (bool, error)) (bool, error) {
+	d.dec.depth++
+	defer func() { d.dec.depth-- }()
+
+	if err := checkLimit("MaxDepth", d.dec.depth, d.dec.opts.Limits.MaxDepth); err != nil {
+		return false, err
+	}
+
+	return fn()
+}
+
+// skip skips the value, leaving its </value> end element unread.
+func (d *ValueDecoder) skip() error {
+	for {
+		tok, err := d.dec.Token()
+		if err != nil {
+			return err
+		}
+
+		switch tok.(type) {
+		case xml.StartElement:
+			if err = d.dec.Skip(); err != nil {
+				return err
+			}
+		case xml.EndElement:
+			d.dec.valueClosed = true
+			return nil
+		}
+	}
+}
+
+// Decode reads the value into value pointed to by v following the usual
+// decoding rules.
+func (d *ValueDecoder) Decode(v interface{}) error {
+	return d.DecodeTagged(v, "")
+}
+
+// DecodeTagged reads the value like Decode, applying options of xmlrpc field
+// tag, e.g. "string" or "format=2006-01-02".
+func (d *ValueDecoder) DecodeTagged(v interface{}, opts string) error {
+	val := reflect.ValueOf(v)
+	if val.Kind() != reflect.Ptr || val.IsNil() {
+		return errors.New("non-pointer value passed to Decode")
+	}
+
+	// the value is counted again by decodeValue
+	d.dec.depth--
+	defer func() { d.dec.depth++ }()
+
+	return d.dec.decodeValue(val.Elem(), tagOptions(opts))
+}
+
+// MissingMembers returns MissingMembersError for members that are required,
+// but weren't found by Struct. It returns nil if the struct value itself is
+// empty, which is decoded as zero value.
+func (d *ValueDecoder) MissingMembers(names []string) error {
+	if d.empty || len(names) == 0 {
+		return nil
+	}
+	return MissingMembersError{Type: d.typ, Members: names}
+}
@@ -0,0 +1,329 @@
+package xmlrpc
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// process has methods in the form generated by xmlrpc-codegen.
+type process struct {
+	Name    string    `xmlrpc:"name,required"`
+	Pid     int64     `xmlrpc:"pid,string"`
+	Load    float64   `xmlrpc:"load,omitempty"`
+	Running bool      `xmlrpc:"running"`
+	Start   time.Time `xmlrpc:"start"`
+	Tags    []string  `xmlrpc:"tags,omitempty"`
+	Parent  *process  `xmlrpc:"parent"`
+}
+
+// plainProcess has fields of process, but not its methods.
+type plainProcess process
+
+func (v process) MarshalXMLRPC(e *ValueEncoder) error {
+	e.BeginStruct()
+	e.BeginMember("name")
+	e.String(v.Name)
+	e.EndMember()
+	e.BeginMember("pid")
+	e.EncodeTagged(&v.Pid, "string")
+	e.EndMember()
+	if v.Load != 0 {
+		e.BeginMember("load")
+		e.Float64(v.Load)
+		e.EndMember()
+	}
+	e.BeginMember("running")
+	e.Bool(v.Running)
+	e.EndMember()
+	e.BeginMember("start")
+	e.Time(v.Start)
+	e.EndMember()
+	if v.Tags != nil {
+		e.BeginMember("tags")
+		e.Encode(&v.Tags)
+		e.EndMember()
+	}
+	e.BeginMember("parent")
+	e.Encode(&v.Parent)
+	e.EndMember()
+	e.EndStruct()
+	return e.Err()
+}
+
+func (v *process) UnmarshalXMLRPC(d *ValueDecoder) error {
+	var seen [1]bool
+	err := d.Struct(func(name string) (bool, error) {
+		var err error
+		switch name {
+		case "name":
+			v.Name, err = d.String()
+			seen[0] = true
+		case "pid":
+			err = d.DecodeTagged(&v.Pid, "string")
+		case "load":
+			v.Load, err = d.Float64()
+		case "running":
+			v.Running, err = d.Bool()
+		case "start":
+			v.Start, err = d.Time()
+		case "tags":
+			err = d.Decode(&v.Tags)
+		case "parent":
+			err = d.Decode(&v.Parent)
+		default:
+			return false, nil
+		}
+		return true, err
+	})
+	if err != nil {
+		return err
+	}
+
+	var missing []string
+	if !seen[0] {
+		missing = append(missing, "name")
+	}
+	if len(missing) > 0 {
+		return d.MissingMembers(missing)
+	}
+	return nil
+}
+
+func Test_marshalerEncodesLikeReflection(t *testing.T) {
+	start := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	tests := []struct {
+		p       process
+		decoded process
+	}{
+		// empty values are decoded into allocated pointers
+		{
+			process{},
+			process{Parent: &process{}},
+		},
+		{
+			process{Name: "web <1>", Pid: 42, Load: 0.5, Running: true, Start: start, Tags: []string{"a", "b"}},
+			process{Name: "web <1>", Pid: 42, Load: 0.5, Running: true, Start: start, Tags: []string{"a", "b"}, Parent: &process{}},
+		},
+		{
+			process{Name: "worker", Parent: &process{Name: "web", Tags: []string{}}},
+			process{Name: "worker", Parent: &process{Name: "web", Parent: &process{}}},
+		},
+	}
+
+	for _, tt := range tests {
+		expected, err := marshal(plainProcess(tt.p))
+		if err != nil {
+			t.Fatalf("marshal error: %v", err)
+		}
+
+		b, err := marshal(tt.p)
+		if err != nil {
+			t.Fatalf("marshal error: %v", err)
+		}
+		if string(b) != string(expected) {
+			t.Fatalf("marshal error:\nexpected: %s\n     got: %s", expected, b)
+		}
+
+		var decoded process
+		if err = unmarshal(b, &decoded); err != nil {
+			t.Fatalf("unmarshal error: %v", err)
+		}
+		if !reflect.DeepEqual(decoded, tt.decoded) {
+			t.Fatalf("unmarshal error:\nexpected: %+v\n     got: %+v", tt.decoded, decoded)
+		}
+	}
+}
+
+func Test_marshalerMaxDepth(t *testing.T) {
+	p := &process{Name: "a", Parent: &process{Name: "b", Tags: []string{"x"}}}
+
+	_, err := (&Encoder{MaxDepth: 3}).Marshal(p)
+	if e, ok := err.(EncodeError); !ok || e.Path != "parent.tags[0]" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func Test_unmarshalerDecodesLikeReflection(t *testing.T) {
+	tests := []struct {
+		xml string
+		dec *Decoder
+	}{
+		{"<value/>", &Decoder{}},
+		{"<value><struct></struct></value>", &Decoder{}},
+		{"<value><struct><member><name>name</name><value>web</value></member></struct></value>", &Decoder{}},
+		{"<value><struct><member><name>name</name><value><string/></value></member><member><name>load</name><value/></member></struct></value>", &Decoder{}},
+		{"<value><struct><member><name>name</name><value>a</value></member><member><name>pid</name><value><string>7</string></value></member><member><name>other</name><value><array><data><value>x</value></data></array></value></member></struct></value>", &Decoder{}},
+		{"<value><struct><member><name>name</name><value>a</value></member><member><name>other</name><value>x</value></member></struct></value>", &Decoder{DisallowUnknownMembers: true}},
+		{"<value><struct><member><name>name</name><value>a</value></member><member><name>name</name><value>b</value></member></struct></value>", &Decoder{DuplicateMembers: DuplicateFirstWins}},
+		{"<value><struct><member><name>name</name><value>a</value></member><member><name>name</name><value>b</value></member></struct></value>", &Decoder{DuplicateMembers: DuplicateError}},
+		{"<value><struct><member><name>load</name><value><int>1</int></value></member></struct></value>", &Decoder{}},
+		{"<value><struct><member><name>running</name><value/></member></struct></value>", &Decoder{}},
+		{"<value><struct><member><name>load</name><value><double/></value></member><member><name>running</name><value/></member></struct></value>", &Decoder{}},
+		{"<value><struct><member><name>name</name><value>a</value></member><member><name>load</name><value><int>1</int></value></member></struct></value>", &Decoder{}},
+		{"<value><struct><member><name>name</name><value>a</value></member><member><name>load</name><value><int>1</int></value></member></struct></value>", &Decoder{CoerceTypes: true}},
+		{"<value><struct><member><name>name</name><value>a</value></member><member><name>running</name><value><string>1</string></value></member></struct></value>", &Decoder{}},
+		{"<value><struct><member><name>name</name><value>a</value></member><member><name>start</name><value><dateTime.iso8601>2020-01-02</dateTime.iso8601></value></member></struct></value>", &Decoder{TimeLayouts: []string{"2006-01-02"}}},
+		{"<value><struct><member><name>name</name><value>a</value></member><member><name>parent</name><value><struct><member><name>name</name><value>b</value></member></struct></value></member></struct></value>", &Decoder{Limits: Limits{MaxDepth: 2}}},
+		{"<value><struct><member><name>name</name><value>a</value></member><member><name>parent</name><value><struct><member><name>name</name><value>b</value></member></struct></value></member></struct></value>", &Decoder{Limits: Limits{MaxDepth: 3}}},
+		{"<value><struct><member><name>name</name><value>abc</value></member></struct></value>", &Decoder{Limits: Limits{MaxStringLength: 2}}},
+		{"<value><struct><member><name>name</name><value>a</value></member><member><name>pid</name><value><string>1</string></value></member></struct></value>", &Decoder{Limits: Limits{MaxStructMembers: 1}}},
+	}
+
+	for _, tt := range tests {
+		var expected plainProcess
+		expectedErr := tt.dec.Unmarshal([]byte(tt.xml), &expected)
+
+		var p process
+		err := tt.dec.Unmarshal([]byte(tt.xml), &p)
+		if !reflect.DeepEqual(p, process(expected)) {
+			t.Fatalf("unmarshal error for %s:\nexpected: %+v\n     got: %+v", tt.xml, expected, p)
+		}
+
+		if expectedErr == nil || err == nil {
+			if err != expectedErr {
+				t.Fatalf("unexpected error for %s:\nexpected: %v\n     got: %v", tt.xml, expectedErr, err)
+			}
+			continue
+		}
+
+		// errors report the type name, which differs
+		expectedMsg := strings.Replace(expectedErr.Error(), "plainProcess", "process", -1)
+		if err.Error() != expectedMsg || reflect.TypeOf(err) != reflect.TypeOf(expectedErr) {
+			t.Fatalf("unexpected error for %s:\nexpected: %v\n     got: %v", tt.xml, expectedErr, err)
+		}
+	}
+}
+
+func Test_unmarshalerTypeMismatch(t *testing.T) {
+	var p process
+	err := unmarshal([]byte("<value><array><data></data></array></value>"), &p)
+	if _, ok := err.(TypeMismatchError); !ok {
+		t.Fatalf("expected TypeMismatchError, got %v", err)
+	}
+}
+
+// matrix encodes and decodes itself as array of arrays with ValueEncoder and
+// ValueDecoder methods.
+type matrix [][]int
+
+func (m matrix) MarshalXMLRPC(e *ValueEncoder) error {
+	e.BeginArray()
+	for _, row := range m {
+		e.BeginArray()
+		for _, v := range row {
+			if v < 0 {
+				e.Encode(func() {})
+			}
+			e.Int(v)
+		}
+		e.EndArray()
+	}
+	e.EndArray()
+	return e.Err()
+}
+
+func (m *matrix) UnmarshalXMLRPC(d *ValueDecoder) error {
+	*m = nil
+	return d.Array(func(i int) error {
+		*m = append(*m, nil)
+		return d.Array(func(j int) error {
+			v, err := d.Int()
+			(*m)[i] = append((*m)[i], v)
+			return err
+		})
+	})
+}
+
+func Test_marshalerArrays(t *testing.T) {
+	m := matrix{{1, 2}, {}, {3}}
+
+	b, err := marshal(m)
+	if err != nil {
+		t.Fatalf("marshal error: %v", err)
+	}
+
+	expected, _ := marshal([][]int{{1, 2}, {}, {3}})
+	if string(b) != string(expected) {
+		t.Fatalf("marshal error:\nexpected: %s\n     got: %s", expected, b)
+	}
+
+	var decoded matrix
+	if err = unmarshal(b, &decoded); err != nil {
+		t.Fatalf("unmarshal error: %v", err)
+	}
+	if !reflect.DeepEqual(decoded, matrix{{1, 2}, nil, {3}}) {
+		t.Fatalf("unexpected value: %v", decoded)
+	}
+
+	_, err = marshal(matrix{{1}, {2, -1}})
+	if e, ok := err.(EncodeError); !ok || e.Path != "[1][1]" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	dec := &Decoder{Limits: Limits{MaxArrayLength: 2}}
+	if err = dec.Unmarshal(b, &decoded); err == nil {
+		t.Fatal("expected LimitError")
+	}
+}
+
+// embedsProcess gets methods of process promoted, which must not be used.
+type embedsProcess struct {
+	process
+	Extra int `xmlrpc:"extra"`
+}
+
+// ownProcess declares its own methods, which shadow promoted ones.
+type ownProcess struct {
+	process
+}
+
+func (v ownProcess) MarshalXMLRPC(e *ValueEncoder) error {
+	e.String("own")
+	return e.Err()
+}
+
+func (v *ownProcess) UnmarshalXMLRPC(d *ValueDecoder) error {
+	var err error
+	v.Name, err = d.String()
+	return err
+}
+
+func Test_marshalerPromotedMethods(t *testing.T) {
+	v := embedsProcess{process: process{Name: "web", Pid: 7}, Extra: 2}
+
+	expected, err := marshal(struct {
+		plainProcess
+		Extra int `xmlrpc:"extra"`
+	}{plainProcess(v.process), v.Extra})
+	if err != nil {
+		t.Fatalf("marshal error: %v", err)
+	}
+
+	b, err := marshal(v)
+	if err != nil {
+		t.Fatalf("marshal error: %v", err)
+	}
+	if string(b) != string(expected) {
+		t.Fatalf("marshal error:\nexpected: %s\n     got: %s", expected, b)
+	}
+
+	var decoded embedsProcess
+	if err = unmarshal(b, &decoded); err != nil {
+		t.Fatalf("unmarshal error: %v", err)
+	}
+	if decoded.Name != "web" || decoded.Pid != 7 || decoded.Extra != 2 {
+		t.Fatalf("unexpected value: %+v", decoded)
+	}
+
+	b, err = marshal(ownProcess{})
+	if err != nil || string(b) != "<value><string>own</string></value>" {
+		t.Fatalf("unexpected result: %s, %v", b, err)
+	}
+
+	var own ownProcess
+	if err = unmarshal(b, &own); err != nil || own.Name != "own" {
+		t.Fatalf("unexpected result: %+v, %v", own, err)
+	}
+}
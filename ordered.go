@@ -0,0 +1,118 @@
+package xmlrpc
+
+import (
+	"reflect"
+)
+
+var (
+	orderedStructType  = reflect.TypeOf(OrderedStruct{})
+	emptyInterfaceType = reflect.TypeOf((*interface{})(nil)).Elem()
+)
+
+// Member is a member of OrderedStruct.
+type Member struct {
+	Name  string
+	Value interface{}
+}
+
+// OrderedStruct holds members of XML-RPC struct in document order. Member
+// values are decoded the same way as values of map[string]interface{}, and
+// are encoded in the order of the slice.
+type OrderedStruct []Member
+
+// Get returns value of the first member with the given name, and reports
+// whether such member exists.
+func (s OrderedStruct) Get(name string) (interface{}, bool) {
+	for _, m := range s {
+		if m.Name == name {
+			return m.Value, true
+		}
+	}
+	return nil, false
+}
+
+func (s OrderedStruct) members() []member {
+	members := make([]member, len(s))
+	for i := range s {
+		members[i] = member{name: s[i].Name, val: reflect.ValueOf(&s[i].Value).Elem()}
+	}
+	return members
+}
+
+// OrderedMap is a map with string keys, that keeps insertion order of its
+// keys. It is encoded as struct with members in that order. The zero value
+// is an empty map ready to use.
+type OrderedMap struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+// NewOrderedMap returns an OrderedMap with keys and values taken from pairs
+// of kv, e.g. NewOrderedMap("user", "joe", "limit", 10). It panics if kv has
+// odd length or keys which aren't strings.
+func NewOrderedMap(kv ...interface{}) *OrderedMap {
+	if len(kv)%2 != 0 {
+		panic("xmlrpc: NewOrderedMap called with odd number of arguments")
+	}
+
+	m := &OrderedMap{}
+	for i := 0; i < len(kv); i += 2 {
+		m.Set(kv[i].(string), kv[i+1])
+	}
+
+	return m
+}
+
+// Set sets value of key. A new key is added after existing keys, while an
+// existing key keeps its position.
+func (m *OrderedMap) Set(key string, value interface{}) {
+	if m.values == nil {
+		m.values = make(map[string]interface{})
+	}
+
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = value
+}
+
+// Get returns value of key and reports whether the key exists.
+func (m *OrderedMap) Get(key string) (interface{}, bool) {
+	v, ok := m.values[key]
+	return v, ok
+}
+
+// Delete removes key from the map.
+func (m *OrderedMap) Delete(key string) {
+	if _, ok := m.values[key]; !ok {
+		return
+	}
+
+	delete(m.values, key)
+	for i, k := range m.keys {
+		if k == key {
+			m.keys = append(m.keys[:i], m.keys[i+1:]...)
+			break
+		}
+	}
+}
+
+// Keys returns keys in insertion order.
+func (m *OrderedMap) Keys() []string {
+	return append([]string(nil), m.keys...)
+}
+
+// Len returns number of keys in the map.
+func (m *OrderedMap) Len() int {
+	return len(m.keys)
+}
+
+func (m *OrderedMap) members() []member {
+	values := reflect.ValueOf(m.values)
+
+	members := make([]member, len(m.keys))
+	for i, key := range m.keys {
+		members[i] = member{name: key, val: values.MapIndex(reflect.ValueOf(key))}
+	}
+	return members
+}
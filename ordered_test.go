@@ -0,0 +1,93 @@
+package xmlrpc
+
+import (
+	"reflect"
+	"testing"
+)
+
+const columnsXML = "<value><struct>" +
+	"<member><name>zeta</name><value><int>1</int></value></member>" +
+	"<member><name>alpha</name><value><string>a</string></value></member>" +
+	"<member><name>mid</name><value><array><data><value><int>2</int></value></data></array></value></member>" +
+	"</struct></value>"
+
+func Test_orderedStructRoundTrip(t *testing.T) {
+	var s OrderedStruct
+	if err := unmarshal([]byte(columnsXML), &s); err != nil {
+		t.Fatalf("unmarshal error: %v", err)
+	}
+
+	expected := OrderedStruct{
+		{"zeta", int64(1)},
+		{"alpha", "a"},
+		{"mid", []interface{}{int64(2)}},
+	}
+	if !reflect.DeepEqual(s, expected) {
+		t.Fatalf("unmarshal error:\nexpected: %v\n     got: %v", expected, s)
+	}
+
+	if v, ok := s.Get("alpha"); !ok || v != "a" {
+		t.Fatalf("unexpected value of alpha: %v", v)
+	}
+
+	b, err := marshal(s)
+	if err != nil {
+		t.Fatalf("marshal error: %v", err)
+	}
+	if string(b) != columnsXML {
+		t.Fatalf("marshal error:\nexpected: %s\n     got: %s", columnsXML, string(b))
+	}
+}
+
+const duplicatesXML = "<value><struct>" +
+	"<member><name>Title</name><value><string>first</string></value></member>" +
+	"<member><name>Amount</name><value><int>1</int></value></member>" +
+	"<member><name>Title</name><value><string>last</string></value></member>" +
+	"</struct></value>"
+
+func Test_decodeDuplicateMembers(t *testing.T) {
+	tests := []struct {
+		policy  DuplicatePolicy
+		ordered OrderedStruct
+		title   string
+	}{
+		{DuplicateLastWins, OrderedStruct{{"Title", "last"}, {"Amount", int64(1)}}, "last"},
+		{DuplicateFirstWins, OrderedStruct{{"Title", "first"}, {"Amount", int64(1)}}, "first"},
+	}
+
+	for _, tt := range tests {
+		dec := &Decoder{DuplicateMembers: tt.policy}
+
+		var s OrderedStruct
+		if err := dec.Unmarshal([]byte(duplicatesXML), &s); err != nil {
+			t.Fatalf("unmarshal error: %v", err)
+		}
+		if !reflect.DeepEqual(s, tt.ordered) {
+			t.Fatalf("unmarshal error:\nexpected: %v\n     got: %v", tt.ordered, s)
+		}
+
+		var b book
+		if err := dec.Unmarshal([]byte(duplicatesXML), &b); err != nil {
+			t.Fatalf("unmarshal error: %v", err)
+		}
+		if b.Title != tt.title {
+			t.Fatalf("expected title %q, got %q", tt.title, b.Title)
+		}
+
+		var m map[string]interface{}
+		if err := dec.Unmarshal([]byte(duplicatesXML), &m); err != nil {
+			t.Fatalf("unmarshal error: %v", err)
+		}
+		if m["Title"] != tt.title {
+			t.Fatalf("expected title %q, got %q", tt.title, m["Title"])
+		}
+	}
+
+	dec := &Decoder{DuplicateMembers: DuplicateError}
+	for _, v := range []interface{}{new(OrderedStruct), new(book), new(map[string]interface{})} {
+		err := dec.Unmarshal([]byte(duplicatesXML), v)
+		if derr, ok := err.(DuplicateMemberError); !ok || derr.Name != "Title" {
+			t.Fatalf("expected DuplicateMemberError for %T, got %v", v, err)
+		}
+	}
+}
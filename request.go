@@ -2,11 +2,21 @@ package xmlrpc
 
 import (
 	"bytes"
+	"encoding/xml"
+	"errors"
 	"fmt"
 	"net/http"
+	"reflect"
+	"strings"
 )
 
 func NewRequest(url string, method string, args interface{}) (*http.Request, error) {
+	return new(Encoder).NewRequest(url, method, args)
+}
+
+// NewRequest works like package level NewRequest, but encodes args with
+// options of e.
+func (e *Encoder) NewRequest(url string, method string, args interface{}) (*http.Request, error) {
 	var t []interface{}
 	var ok bool
 	if t, ok = args.([]interface{}); !ok {
@@ -15,32 +25,62 @@ func NewRequest(url string, method string, args interface{}) (*http.Request, err
 		}
 	}
 
-	body, err := EncodeMethodCall(method, t...)
+	body, err := e.EncodeMethodCall(method, t...)
 	if err != nil {
 		return nil, err
 	}
 
+	if e.ContentEncoding != "" {
+		if body, err = compressBody(body, e.ContentEncoding); err != nil {
+			return nil, err
+		}
+	}
+
 	request, err := http.NewRequest("POST", url, bytes.NewReader(body))
 	if err != nil {
 		return nil, err
 	}
 
-	request.Header.Set("Content-Type", "text/xml")
+	if e.ContentEncoding != "" {
+		request.Header.Set("Content-Encoding", e.ContentEncoding)
+	}
+
+	request.Header.Set("Content-Type", e.contentType())
 	request.Header.Set("Content-Length", fmt.Sprintf("%d", len(body)))
 
 	return request, nil
 }
 
 func EncodeMethodCall(method string, args ...interface{}) ([]byte, error) {
+	return new(Encoder).EncodeMethodCall(method, args...)
+}
+
+// EncodeMethodCall works like package level EncodeMethodCall, but encodes
+// args with options of e.
+func (e *Encoder) EncodeMethodCall(method string, args ...interface{}) ([]byte, error) {
+	if e.StrictMethodNames && !isValidMethodName(method) {
+		return nil, EncodeError{Msg: fmt.Sprintf("invalid method name %q", method)}
+	}
+
+	name, err := escapeName(method)
+	if err != nil {
+		return nil, err
+	}
+
+	decl, err := e.declaration()
+	if err != nil {
+		return nil, err
+	}
+
 	var b bytes.Buffer
-	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>`)
-	b.WriteString(fmt.Sprintf("<methodCall><methodName>%s</methodName>", method))
+	b.WriteString(decl)
+	b.WriteString(fmt.Sprintf("<methodCall><methodName>%s</methodName>", name))
 
 	if args != nil {
 		b.WriteString("<params>")
 
-		for _, arg := range args {
-			p, err := marshal(arg)
+		for i, arg := range args {
+			p, err := e.marshal(arg, pathElem{name: "params"}, pathElem{index: i})
 			if err != nil {
 				return nil, err
 			}
@@ -53,5 +93,122 @@ func EncodeMethodCall(method string, args ...interface{}) ([]byte, error) {
 
 	b.WriteString("</methodCall>")
 
-	return b.Bytes(), nil
+	return e.transcode(b.Bytes())
+}
+
+// declaration returns XML declaration of documents encoded with Charset.
+func (e *Encoder) declaration() (string, error) {
+	charset := "UTF-8"
+	if e.Charset != "" {
+		var err error
+		if charset, err = charsetName(e.Charset); err != nil {
+			return "", EncodeError{Msg: err.Error()}
+		}
+	}
+
+	return fmt.Sprintf(`<?xml version="1.0" encoding="%s"?>`, charset), nil
+}
+
+// transcode converts UTF-8 document data to Charset.
+func (e *Encoder) transcode(data []byte) ([]byte, error) {
+	if e.Charset == "" {
+		return data, nil
+	}
+	return encodeCharset(data, e.Charset)
+}
+
+// contentType returns Content-Type header value of documents encoded with
+// Charset.
+func (e *Encoder) contentType() string {
+	if e.Charset == "" {
+		return "text/xml"
+	}
+	if charset, err := charsetName(e.Charset); err == nil {
+		return "text/xml; charset=" + charset
+	}
+	return "text/xml; charset=" + e.Charset
+}
+
+// unmarshalMethodCall parses method call in data and returns its method name.
+// If args is nil, params are decoded into generic Go values and returned,
+// otherwise params are decoded into values pointed to by args, which must
+// match the number of params.
+func (d *Decoder) unmarshalMethodCall(data []byte, args []interface{}) (method string, params []interface{}, err error) {
+	dec := d.newDecoder(bytes.NewReader(data))
+
+	tok, err := dec.nextElement()
+	if err != nil {
+		return "", nil, err
+	}
+	if t, ok := tok.(xml.StartElement); !ok || t.Name.Local != "methodCall" {
+		return "", nil, invalidXmlError
+	}
+
+	name, value, err := dec.readTag()
+	if err != nil {
+		return "", nil, err
+	}
+	if name != "methodName" {
+		return "", nil, invalidXmlError
+	}
+	method = strings.TrimSpace(string(value))
+
+	var n int
+	for {
+		tok, err := dec.nextElement()
+		if err != nil {
+			return "", nil, err
+		}
+
+		t, ok := tok.(xml.StartElement)
+		if !ok {
+			// </params> or </methodCall>
+			if tok.(xml.EndElement).Name.Local == "methodCall" {
+				break
+			}
+			continue
+		}
+
+		switch t.Name.Local {
+		case "params":
+			continue
+		case "param":
+			if _, err = dec.findStart("value"); err != nil {
+				return "", nil, err
+			}
+		default:
+			return "", nil, invalidXmlError
+		}
+
+		var val reflect.Value
+		if args == nil {
+			params = append(params, nil)
+			val = reflect.ValueOf(&params[n]).Elem()
+		} else if n < len(args) {
+			if val = reflect.ValueOf(args[n]); val.Kind() != reflect.Ptr || val.IsNil() {
+				return "", nil, errors.New("non-pointer value passed to unmarshal")
+			}
+			val = val.Elem()
+		} else {
+			return "", nil, fmt.Errorf("error: method call has more than %d params", len(args))
+		}
+
+		if err = dec.decodeValue(val, ""); err != nil {
+			return "", nil, err
+		}
+		if err = dec.skipValueEnd(); err != nil {
+			return "", nil, err
+		}
+		// </param>
+		if err = dec.Skip(); err != nil {
+			return "", nil, err
+		}
+		n++
+	}
+
+	if args != nil && n < len(args) {
+		return "", nil, fmt.Errorf("error: method call has %d params, expected %d", n, len(args))
+	}
+
+	return method, params, nil
 }
@@ -0,0 +1,111 @@
+package xmlrpc
+
+import (
+	"strings"
+	"testing"
+)
+
+func Test_encodeMethodCallEscapesName(t *testing.T) {
+	b, err := EncodeMethodCall("system.<evil>&")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := `<?xml version="1.0" encoding="UTF-8"?><methodCall><methodName>system.&lt;evil&gt;&amp;</methodName></methodCall>`
+	if string(b) != expected {
+		t.Fatalf("encode error:\nexpected: %s\n     got: %s", expected, string(b))
+	}
+}
+
+func Test_encodeMethodCallStrictNames(t *testing.T) {
+	enc := &Encoder{StrictMethodNames: true}
+
+	for _, name := range []string{"system.listMethods", "service/v2:sum_all"} {
+		if _, err := enc.EncodeMethodCall(name); err != nil {
+			t.Fatalf("unexpected error for %q: %v", name, err)
+		}
+	}
+
+	for _, name := range []string{"", "system listMethods", "system.<evil>", "сервис.сумма"} {
+		_, err := enc.EncodeMethodCall(name)
+		if _, ok := err.(EncodeError); !ok {
+			t.Fatalf("expected EncodeError for %q, got %v", name, err)
+		}
+	}
+
+	if _, err := EncodeMethodCall("bad\x00name"); err == nil {
+		t.Fatal("expected error for name with NUL character, but didn't get it")
+	}
+}
+
+func Test_encodeMethodCallCharset(t *testing.T) {
+	enc := &Encoder{Charset: "windows-1251"}
+
+	b, err := enc.EncodeMethodCall("library.add", "Война и Мир")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := `<?xml version="1.0" encoding="windows-1251"?><methodCall><methodName>library.add</methodName><params><param><value><string>` +
+		"\xc2\xee\xe9\xed\xe0 \xe8 \xcc\xe8\xf0" + `</string></value></param></params></methodCall>`
+	if string(b) != expected {
+		t.Fatalf("encode error:\nexpected: %q\n     got: %q", expected, string(b))
+	}
+
+	var s string
+	if err := unmarshal(b, &s); err != nil {
+		t.Fatalf("unmarshal error: %v", err)
+	}
+	if s != "Война и Мир" {
+		t.Fatalf("unexpected decoded value: %s", s)
+	}
+
+	if _, err := enc.EncodeMethodCall("library.add", "War and Peace ☮"); err == nil {
+		t.Fatal("expected error for unrepresentable character, but didn't get it")
+	} else if _, ok := err.(EncodeError); !ok {
+		t.Fatalf("expected EncodeError, got %v", err)
+	}
+
+	if _, err := (&Encoder{Charset: "klingon"}).EncodeMethodCall("library.add"); err == nil {
+		t.Fatal("expected error for unsupported charset, but didn't get it")
+	}
+
+	req, err := enc.NewRequest("http://localhost", "library.add", "Война и Мир")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ct := req.Header.Get("Content-Type"); ct != "text/xml; charset=windows-1251" {
+		t.Fatalf("unexpected Content-Type: %s", ct)
+	}
+}
+
+func Test_encodeMethodCallCharsetAlias(t *testing.T) {
+	tests := []struct {
+		charset  string
+		expected string
+	}{
+		{"cp1251", "windows-1251"},
+		{"latin1", "ISO-8859-1"},
+		{" UTF8 ", "UTF-8"},
+	}
+
+	for _, tt := range tests {
+		enc := &Encoder{Charset: tt.charset}
+
+		req, err := enc.NewRequest("http://localhost", "library.add", "War and Peace")
+		if err != nil {
+			t.Fatalf("unexpected error for %s: %v", tt.charset, err)
+		}
+		if ct := req.Header.Get("Content-Type"); ct != "text/xml; charset="+tt.expected {
+			t.Fatalf("unexpected Content-Type for %s: %s", tt.charset, ct)
+		}
+
+		b, err := enc.EncodeMethodCall("library.add")
+		if err != nil {
+			t.Fatalf("unexpected error for %s: %v", tt.charset, err)
+		}
+		if decl := `<?xml version="1.0" encoding="` + tt.expected + `"?>`; !strings.HasPrefix(string(b), decl) {
+			t.Fatalf("unexpected declaration for %s: %s", tt.charset, b)
+		}
+	}
+}
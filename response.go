@@ -1,6 +1,7 @@
 package xmlrpc
 
 import (
+	"bytes"
 	"fmt"
 	"regexp"
 )
@@ -23,11 +24,16 @@ func (e FaultError) Error() string {
 type Response []byte
 
 func (r Response) Err() error {
+	return r.err(new(Decoder))
+}
+
+// err works like Err, but decodes the fault with options of d.
+func (r Response) err(d *Decoder) error {
 	if !faultRx.Match(r) {
 		return nil
 	}
 	var fault FaultError
-	if err := unmarshal(r, &fault); err != nil {
+	if err := d.unmarshal(r, &fault); err != nil {
 		return err
 	}
 	return fault
@@ -40,3 +46,60 @@ func (r Response) Unmarshal(v interface{}) error {
 
 	return nil
 }
+
+// EncodeMethodResponse returns XML-RPC method response with result as its
+// only param. A nil result is encoded as an empty value.
+func EncodeMethodResponse(result interface{}) ([]byte, error) {
+	return new(Encoder).EncodeMethodResponse(result)
+}
+
+// EncodeMethodResponse works like package level EncodeMethodResponse, but
+// encodes result with options of e.
+func (e *Encoder) EncodeMethodResponse(result interface{}) ([]byte, error) {
+	decl, err := e.declaration()
+	if err != nil {
+		return nil, err
+	}
+
+	p := []byte("<value/>")
+	if result != nil {
+		if p, err = e.marshal(result, pathElem{name: "result"}); err != nil {
+			return nil, err
+		}
+	}
+
+	var b bytes.Buffer
+	b.WriteString(decl)
+	b.WriteString("<methodResponse><params><param>")
+	b.Write(p)
+	b.WriteString("</param></params></methodResponse>")
+
+	return e.transcode(b.Bytes())
+}
+
+// EncodeFault returns XML-RPC method response holding fault.
+func EncodeFault(fault FaultError) ([]byte, error) {
+	return new(Encoder).EncodeFault(fault)
+}
+
+// EncodeFault works like package level EncodeFault, but encodes fault with
+// options of e.
+func (e *Encoder) EncodeFault(fault FaultError) ([]byte, error) {
+	decl, err := e.declaration()
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := e.marshal(fault)
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	b.WriteString(decl)
+	b.WriteString("<methodResponse><fault>")
+	b.Write(p)
+	b.WriteString("</fault></methodResponse>")
+
+	return e.transcode(b.Bytes())
+}
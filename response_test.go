@@ -77,3 +77,42 @@ func Test_responseWithEmptyValue(t *testing.T) {
 		t.Fatalf("unexpected result: %v", result)
 	}
 }
+
+func Test_encodeMethodResponse(t *testing.T) {
+	data, err := EncodeMethodResponse(book{"War and Piece", 20})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	resp := Response(data)
+	if err := resp.Err(); err != nil {
+		t.Fatalf("unexpected fault: %v", err)
+	}
+	var b book
+	if err := resp.Unmarshal(&b); err != nil {
+		t.Fatalf("unmarshal error: %v", err)
+	}
+	if b != (book{"War and Piece", 20}) {
+		t.Fatalf("unexpected result: %v", b)
+	}
+
+	if data, err = EncodeMethodResponse(nil); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expected := `<?xml version="1.0" encoding="UTF-8"?><methodResponse><params><param><value/></param></params></methodResponse>`
+	if string(data) != expected {
+		t.Fatalf("unexpected response:\nexpected: %s\n     got: %s", expected, data)
+	}
+}
+
+func Test_encodeFault(t *testing.T) {
+	data, err := EncodeFault(FaultError{Code: 410, String: "You must log in"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	fault, ok := Response(data).Err().(FaultError)
+	if !ok || fault.Code != 410 || fault.String != "You must log in" {
+		t.Fatalf("unexpected fault: %v", Response(data).Err())
+	}
+}
@@ -0,0 +1,293 @@
+package xmlrpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"runtime/debug"
+	"sync"
+)
+
+// minCompressSize is the minimal size of a response body compressed by
+// Server.
+const minCompressSize = 1024
+
+// Handler handles a method call and returns its result. Returning FaultError
+// sends it to the client as is.
+type Handler func(ctx context.Context, call *Call) (interface{}, error)
+
+// Middleware runs around handlers of all method calls, after the method call
+// has been parsed. It continues the call by calling next, possibly with a
+// context holding additional values, or rejects it by returning an error,
+// usually FaultError.
+type Middleware func(ctx context.Context, call *Call, next Handler) (interface{}, error)
+
+// Call is a method call received by Server.
+type Call struct {
+	// Method is the name of the called method.
+	Method string
+
+	// Params holds params of the call decoded into generic Go values: maps
+	// for structs, slices for arrays and basic types for scalars.
+	Params []interface{}
+
+	body    []byte
+	decoder *Decoder
+}
+
+// Decode decodes params of the call into values pointed to by args. The
+// number of args must match the number of params. It fails with
+// InvalidParamsError, which Server sends to client as invalid params fault.
+func (c *Call) Decode(args ...interface{}) error {
+	if args == nil {
+		args = []interface{}{}
+	}
+	if _, _, err := c.decoder.unmarshalMethodCall(c.body, args); err != nil {
+		return InvalidParamsError{Err: err}
+	}
+	return nil
+}
+
+// InvalidParamsError is returned when params of a method call don't match
+// the method. Server sends it to client as invalid params fault.
+type InvalidParamsError struct {
+	Err error
+}
+
+func (e InvalidParamsError) Error() string {
+	return fmt.Sprintf("invalid params: %v", e.Err)
+}
+
+func (e InvalidParamsError) Unwrap() error { return e.Err }
+
+type contextKey int
+
+const (
+	requestKey contextKey = iota
+	methodKey
+	mapOrderKey
+)
+
+// RequestFromContext returns HTTP request of a method call handled by Server.
+func RequestFromContext(ctx context.Context) (*http.Request, bool) {
+	r, ok := ctx.Value(requestKey).(*http.Request)
+	return r, ok
+}
+
+// MethodFromContext returns method name of a method call handled by Server.
+func MethodFromContext(ctx context.Context) (string, bool) {
+	method, ok := ctx.Value(methodKey).(string)
+	return method, ok
+}
+
+// Server is an http.Handler serving XML-RPC method calls.
+type Server struct {
+	// Encoder is used to encode responses. If nil, responses are encoded
+	// with default options.
+	Encoder *Encoder
+
+	// Decoder is used to decode method calls. If nil, method calls are
+	// decoded with default options.
+	Decoder *Decoder
+
+	// MapError converts errors returned by handlers and middleware, except
+	// FaultError, which is sent as is, to faults. If it is nil or returns
+	// false, InvalidParamsError becomes invalid params fault and other
+	// errors become internal error faults with the error message.
+	MapError func(ctx context.Context, err error) (FaultError, bool)
+
+	// ErrorLog logs panics recovered in handlers and middleware along with
+	// stack trace. If nil, panics aren't logged.
+	ErrorLog *log.Logger
+
+	mutex      sync.RWMutex
+	handlers   map[string]Handler
+	middleware []Middleware
+}
+
+// NewServer returns Server without registered methods.
+func NewServer() *Server {
+	return &Server{handlers: make(map[string]Handler)}
+}
+
+// Handle registers handler for method, replacing the previous one, if any.
+func (s *Server) Handle(method string, handler Handler) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.handlers == nil {
+		s.handlers = make(map[string]Handler)
+	}
+	s.handlers[method] = handler
+}
+
+// Use appends middleware to the chain run around handlers. The first
+// middleware is the outermost one.
+func (s *Server) Use(middleware ...Middleware) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.middleware = append(s.middleware, middleware...)
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		w.Header().Set("Allow", "POST")
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	decoder := s.Decoder
+	if decoder == nil {
+		decoder = &Decoder{}
+	}
+
+	call, err := s.readCall(r, decoder)
+	if err != nil {
+		fault := parseFault(err)
+		s.writeResult(w, r, nil, &fault)
+		return
+	}
+
+	ctx := context.WithValue(r.Context(), requestKey, r)
+	ctx = context.WithValue(ctx, methodKey, call.Method)
+
+	s.mutex.RLock()
+	handler := chainMiddleware(s.middleware, s.dispatch)
+	s.mutex.RUnlock()
+
+	result, err := s.call(ctx, handler, call)
+	if err != nil {
+		fault := s.fault(ctx, err)
+		s.writeResult(w, r, nil, &fault)
+		return
+	}
+
+	s.writeResult(w, r, result, nil)
+}
+
+// call runs handler, converting its panic to internal error fault.
+func (s *Server) call(ctx context.Context, handler Handler, call *Call) (result interface{}, err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			if v == http.ErrAbortHandler {
+				panic(v)
+			}
+			if s.ErrorLog != nil {
+				s.ErrorLog.Printf("xmlrpc: panic serving %s: %v\n%s", call.Method, v, debug.Stack())
+			}
+			result, err = nil, FaultError{Code: FaultInternalError, String: "internal error"}
+		}
+	}()
+
+	return handler(ctx, call)
+}
+
+// fault converts err returned by handler to FaultError.
+func (s *Server) fault(ctx context.Context, err error) FaultError {
+	var fault FaultError
+	if errors.As(err, &fault) {
+		return fault
+	}
+
+	if s.MapError != nil {
+		if fault, ok := s.MapError(ctx, err); ok {
+			return fault
+		}
+	}
+
+	var paramsErr InvalidParamsError
+	if errors.As(err, &paramsErr) {
+		return FaultError{Code: FaultInvalidParams, String: err.Error()}
+	}
+
+	return FaultError{Code: FaultInternalError, String: err.Error()}
+}
+
+// readCall reads and parses method call from body of r.
+func (s *Server) readCall(r *http.Request, decoder *Decoder) (*Call, error) {
+	body, err := decompressBody(r.Body, r.Header.Get("Content-Encoding"))
+	if err != nil {
+		return nil, err
+	}
+
+	if body, err = decoder.bodyReader(body, r.Header.Get("Content-Type")); err != nil {
+		return nil, err
+	}
+
+	data, err := decoder.ReadBody(body)
+	if err != nil {
+		return nil, err
+	}
+
+	method, params, err := decoder.unmarshalMethodCall(data, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Call{Method: method, Params: params, body: data, decoder: decoder}, nil
+}
+
+// dispatch is the Handler which calls handler registered for the method.
+func (s *Server) dispatch(ctx context.Context, call *Call) (interface{}, error) {
+	s.mutex.RLock()
+	handler, ok := s.handlers[call.Method]
+	s.mutex.RUnlock()
+
+	if !ok {
+		return nil, FaultError{Code: FaultMethodNotFound, String: fmt.Sprintf("method not found: %s", call.Method)}
+	}
+
+	return handler(ctx, call)
+}
+
+// writeResult writes method response holding result, or fault if it isn't
+// nil.
+func (s *Server) writeResult(w http.ResponseWriter, r *http.Request, result interface{}, fault *FaultError) {
+	encoder := s.Encoder
+	if encoder == nil {
+		encoder = &Encoder{}
+	}
+
+	var body []byte
+	var err error
+	if fault == nil {
+		if body, err = encoder.EncodeMethodResponse(result); err != nil {
+			fault = &FaultError{Code: FaultInternalError, String: err.Error()}
+		}
+	}
+	if fault != nil {
+		if body, err = encoder.EncodeFault(*fault); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", encoder.contentType())
+	w.Header().Add("Vary", "Accept-Encoding")
+
+	if len(body) >= minCompressSize && acceptsEncoding(r.Header.Get("Accept-Encoding"), "gzip") {
+		if compressed, err := compressBody(body, "gzip"); err == nil {
+			w.Header().Set("Content-Encoding", "gzip")
+			body = compressed
+		}
+	}
+
+	w.Header().Set("Content-Length", fmt.Sprintf("%d", len(body)))
+	w.Write(body)
+}
+
+// chainMiddleware returns handler running middleware in order around
+// handler, so the first middleware is the outermost one.
+func chainMiddleware(middleware []Middleware, handler Handler) Handler {
+	for i := len(middleware) - 1; i >= 0; i-- {
+		m, next := middleware[i], handler
+		handler = func(ctx context.Context, call *Call) (interface{}, error) {
+			return m(ctx, call, next)
+		}
+	}
+	return handler
+}
@@ -0,0 +1,237 @@
+package xmlrpc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type userKey struct{}
+
+func newTestServer() *Server {
+	s := NewServer()
+
+	s.Handle("library.add", func(ctx context.Context, call *Call) (interface{}, error) {
+		var b book
+		var count int
+		if err := call.Decode(&b, &count); err != nil {
+			return nil, err
+		}
+		b.Amount += count
+		return b, nil
+	})
+
+	s.Handle("library.whoami", func(ctx context.Context, call *Call) (interface{}, error) {
+		r, ok := RequestFromContext(ctx)
+		if !ok {
+			return nil, FaultError{Code: 1, String: "no request"}
+		}
+		method, _ := MethodFromContext(ctx)
+		return []string{ctx.Value(userKey{}).(string), method, r.Header.Get("User-Agent")}, nil
+	})
+
+	s.Handle("library.export", func(ctx context.Context, call *Call) (interface{}, error) {
+		books := make([]book, 100)
+		for i := range books {
+			books[i] = book{"War and Piece", i}
+		}
+		return books, nil
+	})
+
+	s.Use(func(ctx context.Context, call *Call, next Handler) (interface{}, error) {
+		r, _ := RequestFromContext(ctx)
+		user := r.Header.Get("X-User")
+		if user == "" {
+			return nil, FaultError{Code: 403, String: "unauthorized"}
+		}
+		return next(context.WithValue(ctx, userKey{}, user), call)
+	})
+
+	return s
+}
+
+type userTransport struct{}
+
+func (userTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r.Header.Set("X-User", "tolstoy")
+	r.Header.Set("User-Agent", "test")
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func Test_server(t *testing.T) {
+	ts := httptest.NewServer(newTestServer())
+	defer ts.Close()
+
+	client, err := NewClient(ts.URL, userTransport{})
+	if err != nil {
+		t.Fatalf("Can't create client: %v", err)
+	}
+	defer client.Close()
+
+	var b book
+	if err := client.Call("library.add", []interface{}{book{"War and Piece", 1}, 2}, &b); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if b != (book{"War and Piece", 3}) {
+		t.Fatalf("unexpected result: %v", b)
+	}
+
+	var who []string
+	if err := client.Call("library.whoami", nil, &who); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if strings.Join(who, ",") != "tolstoy,library.whoami,test" {
+		t.Fatalf("unexpected result: %v", who)
+	}
+
+	var books []book
+	if err := client.Call("library.export", nil, &books); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(books) != 100 || books[99].Amount != 99 {
+		t.Fatalf("unexpected result: %v", books)
+	}
+
+	err = client.Call("library.add", []interface{}{book{"War and Piece", 1}}, &b)
+	if err == nil || !strings.Contains(err.Error(), "Fault(-32602)") {
+		t.Fatalf("expected invalid params fault, got %v", err)
+	}
+
+	err = client.Call("library.remove", nil, nil)
+	if err == nil || !strings.Contains(err.Error(), "Fault(-32601)") {
+		t.Fatalf("expected method not found fault, got %v", err)
+	}
+}
+
+func Test_serverMiddlewareRejects(t *testing.T) {
+	ts := httptest.NewServer(newTestServer())
+	defer ts.Close()
+
+	client, err := NewClient(ts.URL, nil)
+	if err != nil {
+		t.Fatalf("Can't create client: %v", err)
+	}
+	defer client.Close()
+
+	err = client.Call("library.whoami", nil, nil)
+	if err == nil || err.Error() != "Fault(403): unauthorized" {
+		t.Fatalf("expected unauthorized fault, got %v", err)
+	}
+}
+
+func Test_serverInvalidRequests(t *testing.T) {
+	s := newTestServer()
+
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected status %d, got %d", http.StatusMethodNotAllowed, w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	s.ServeHTTP(w, httptest.NewRequest("POST", "/", strings.NewReader("<methodCall><methodName>")))
+	if err := Response(w.Body.Bytes()).Err(); err == nil || !strings.Contains(err.Error(), "Fault(-32700)") {
+		t.Fatalf("expected parse error fault, got %v", err)
+	}
+}
+
+func Test_unmarshalMethodCall(t *testing.T) {
+	data, err := EncodeMethodCall("library.add", book{"War and Piece", 1}, 2, []string{"a", "b"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	method, params, err := new(Decoder).unmarshalMethodCall(data, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if method != "library.add" || len(params) != 3 {
+		t.Fatalf("unexpected method call: %s %v", method, params)
+	}
+	if m, ok := params[0].(map[string]interface{}); !ok || m["Title"] != "War and Piece" {
+		t.Fatalf("unexpected first param: %#v", params[0])
+	}
+
+	var b book
+	var n int
+	var s []string
+	if _, _, err := new(Decoder).unmarshalMethodCall(data, []interface{}{&b, &n, &s}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if b.Title != "War and Piece" || n != 2 || len(s) != 2 {
+		t.Fatalf("unexpected params: %v %v %v", b, n, s)
+	}
+
+	if _, _, err := new(Decoder).unmarshalMethodCall(data, []interface{}{&b, &n}); err == nil {
+		t.Fatal("expected error for extra params, but didn't get it")
+	}
+	if _, _, err := new(Decoder).unmarshalMethodCall(data, []interface{}{&b, &n, &s, &n}); err == nil {
+		t.Fatal("expected error for missing params, but didn't get it")
+	}
+}
+
+var errNotFound = errors.New("book not found")
+
+func Test_serverErrorFaults(t *testing.T) {
+	var logged bytes.Buffer
+
+	s := NewServer()
+	s.ErrorLog = log.New(&logged, "", 0)
+	s.MapError = func(ctx context.Context, err error) (FaultError, bool) {
+		if errors.Is(err, errNotFound) {
+			return FaultError{Code: 404, String: err.Error()}, true
+		}
+		return FaultError{}, false
+	}
+
+	s.Handle("library.panic", func(ctx context.Context, call *Call) (interface{}, error) {
+		panic("out of books")
+	})
+	s.Handle("library.get", func(ctx context.Context, call *Call) (interface{}, error) {
+		return nil, fmt.Errorf("library.get: %w", errNotFound)
+	})
+	s.Handle("library.fail", func(ctx context.Context, call *Call) (interface{}, error) {
+		return nil, errors.New("disk is full")
+	})
+	s.Handle("library.fault", func(ctx context.Context, call *Call) (interface{}, error) {
+		return nil, fmt.Errorf("wrapped: %w", FaultError{Code: 410, String: "gone"})
+	})
+	s.Handle("library.params", func(ctx context.Context, call *Call) (interface{}, error) {
+		var n int
+		return nil, call.Decode(&n)
+	})
+
+	tests := []struct {
+		method   string
+		expected string
+	}{
+		{"library.panic", "Fault(-32603): internal error"},
+		{"library.get", "Fault(404): library.get: book not found"},
+		{"library.fail", "Fault(-32603): disk is full"},
+		{"library.fault", "Fault(410): gone"},
+		{"library.params", "Fault(-32602): invalid params: error: method call has more than 1 params"},
+	}
+
+	for _, tt := range tests {
+		body, _ := EncodeMethodCall(tt.method, 1, 2)
+
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, httptest.NewRequest("POST", "/", bytes.NewReader(body)))
+
+		err := Response(w.Body.Bytes()).Err()
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("%s: expected %q, got %v", tt.method, tt.expected, err)
+		}
+	}
+
+	if !strings.Contains(logged.String(), "panic serving library.panic: out of books") ||
+		!strings.Contains(logged.String(), "goroutine") {
+		t.Fatalf("panic isn't logged with stack trace: %s", logged.String())
+	}
+}
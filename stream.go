@@ -0,0 +1,207 @@
+package xmlrpc
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// ArrayDecoder decodes elements of an array value one at a time while reading
+// them from an io.Reader, so only a single element is kept in memory.
+//
+//	arr, err := client.StreamArray("export.books", nil)
+//	if err != nil {
+//		return err
+//	}
+//	defer arr.Close()
+//
+//	for arr.Next() {
+//		var b book
+//		if err := arr.Decode(&b); err != nil {
+//			return err
+//		}
+//	}
+//	return arr.Err()
+type ArrayDecoder struct {
+	dec    *decoder
+	closer io.Closer
+	err    error
+
+	// n is the number of elements found so far.
+	n int
+
+	// pending reports that Next has consumed the <value> start element of
+	// an element that isn't decoded yet.
+	pending bool
+	done    bool
+}
+
+// NewArrayDecoder reads a response, or a single value, from r up to the first
+// element of its array value. If the response is a fault, FaultError is
+// returned.
+func (d *Decoder) NewArrayDecoder(r io.Reader) (*ArrayDecoder, error) {
+	dec := d.newDecoder(d.limitBody(r))
+
+	start, err := dec.findStart("value", "fault")
+	if err != nil {
+		return nil, err
+	}
+
+	if start == "fault" {
+		if _, err = dec.findStart("value"); err != nil {
+			return nil, err
+		}
+
+		var fault FaultError
+		if err = dec.decodeValue(reflect.ValueOf(&fault).Elem(), ""); err != nil {
+			return nil, err
+		}
+
+		return nil, fault
+	}
+
+	dec.depth = 1
+	if err = checkLimit("MaxDepth", dec.depth, d.Limits.MaxDepth); err != nil {
+		return nil, err
+	}
+
+	for _, name := range []string{"array", "data"} {
+		tok, err := dec.nextElement()
+		if err != nil {
+			return nil, err
+		}
+
+		t, ok := tok.(xml.StartElement)
+		if !ok {
+			return nil, TypeMismatchError("error: type mismatch - can't decode empty value as array")
+		}
+		if t.Name.Local != name {
+			if name == "array" {
+				return nil, TypeMismatchError(fmt.Sprintf("error: type mismatch - can't decode %s as array", t.Name.Local))
+			}
+			return nil, invalidXmlError
+		}
+	}
+
+	return &ArrayDecoder{dec: dec}, nil
+}
+
+// Next prepares the next array element to be decoded with Decode. It returns
+// false when there are no more elements or an error happened, which is
+// reported by Err.
+func (a *ArrayDecoder) Next() bool {
+	if a.err != nil || a.done {
+		return false
+	}
+
+	// skip an element that wasn't decoded
+	if a.pending {
+		a.pending = false
+		if a.err = a.dec.Skip(); a.err != nil {
+			return false
+		}
+	}
+
+	tok, err := a.dec.nextElement()
+	if err != nil {
+		a.err = err
+		return false
+	}
+
+	switch t := tok.(type) {
+	case xml.StartElement:
+		if t.Name.Local != "value" {
+			a.err = invalidXmlError
+			return false
+		}
+
+		a.n++
+		if a.err = checkLimit("MaxArrayLength", a.n, a.dec.opts.Limits.MaxArrayLength); a.err != nil {
+			return false
+		}
+
+		a.pending = true
+		return true
+	default:
+		// </data>
+		a.done = true
+		return false
+	}
+}
+
+// Decode decodes the current array element into the value pointed to by v.
+func (a *ArrayDecoder) Decode(v interface{}) error {
+	if !a.pending {
+		return errors.New("error: Decode called without successful Next")
+	}
+
+	val := reflect.ValueOf(v)
+	if val.Kind() != reflect.Ptr {
+		return errors.New("non-pointer value passed to Decode")
+	}
+
+	a.pending = false
+	if a.err = a.dec.decodeValue(val.Elem(), ""); a.err != nil {
+		return a.err
+	}
+
+	// </value>
+	a.err = a.dec.skipValueEnd()
+	return a.err
+}
+
+// Err returns the error, if any, that was encountered while reading elements.
+func (a *ArrayDecoder) Err() error {
+	return a.err
+}
+
+// Close closes the underlying response body, if any.
+func (a *ArrayDecoder) Close() error {
+	if a.closer == nil {
+		return nil
+	}
+	return a.closer.Close()
+}
+
+// findStart reads tokens until a start element with one of names is consumed
+// and returns its name.
+func (dec *decoder) findStart(names ...string) (string, error) {
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return "", err
+		}
+
+		if t, ok := tok.(xml.StartElement); ok {
+			for _, name := range names {
+				if t.Name.Local == name {
+					return name, nil
+				}
+			}
+		}
+	}
+}
+
+// nextElement returns the next start or end element, skipping character data,
+// comments and other tokens between them.
+func (dec *decoder) nextElement() (xml.Token, error) {
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			if err == io.EOF {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+
+		switch tok.(type) {
+		case xml.StartElement, xml.EndElement:
+			return tok, nil
+		}
+	}
+}
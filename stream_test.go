@@ -0,0 +1,145 @@
+package xmlrpc
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const booksRespXML = `
+<?xml version="1.0" encoding="UTF-8"?>
+<methodResponse>
+  <params>
+    <param>
+      <value>
+        <array>
+          <data>
+            <value><struct><member><name>Title</name><value><string>War and Piece</string></value></member><member><name>Amount</name><value><int>20</int></value></member></struct></value>
+            <value/>
+            <value><struct><member><name>Title</name><value>Anna Karenina</value></member></struct></value>
+          </data>
+        </array>
+      </value>
+    </param>
+  </params>
+</methodResponse>`
+
+func Test_arrayDecoder(t *testing.T) {
+	arr, err := new(Decoder).NewArrayDecoder(strings.NewReader(booksRespXML))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var books []book
+	for arr.Next() {
+		var b book
+		if err := arr.Decode(&b); err != nil {
+			t.Fatalf("decode error: %v", err)
+		}
+		books = append(books, b)
+	}
+	if err := arr.Err(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []book{{"War and Piece", 20}, {}, {"Anna Karenina", 0}}
+	if len(books) != len(expected) {
+		t.Fatalf("expected %d elements, got %d", len(expected), len(books))
+	}
+	for i := range expected {
+		if books[i] != expected[i] {
+			t.Fatalf("element %d:\nexpected: %v\n     got: %v", i, expected[i], books[i])
+		}
+	}
+}
+
+func Test_arrayDecoderSkipElements(t *testing.T) {
+	arr, err := new(Decoder).NewArrayDecoder(strings.NewReader(booksRespXML))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var n int
+	for arr.Next() {
+		n++
+	}
+	if err := arr.Err(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n != 3 {
+		t.Fatalf("expected 3 elements, got %d", n)
+	}
+}
+
+func Test_arrayDecoderErrors(t *testing.T) {
+	if _, err := new(Decoder).NewArrayDecoder(strings.NewReader(faultRespXml)); err == nil {
+		t.Fatal("expected fault error, but didn't get it")
+	} else if fault, ok := err.(FaultError); !ok || fault.Code != 410 {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := new(Decoder).NewArrayDecoder(strings.NewReader(emptyValResp)); err == nil {
+		t.Fatal("expected type mismatch error, but didn't get it")
+	} else if _, ok := err.(TypeMismatchError); !ok {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	dec := &Decoder{Limits: Limits{MaxArrayLength: 2}}
+	arr, err := dec.NewArrayDecoder(strings.NewReader(booksRespXML))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for arr.Next() {
+	}
+	if _, ok := arr.Err().(LimitError); !ok {
+		t.Fatalf("expected LimitError, got %v", arr.Err())
+	}
+
+	dec = &Decoder{Limits: Limits{MaxBodySize: 300}}
+	arr, err = dec.NewArrayDecoder(strings.NewReader(booksRespXML))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for arr.Next() {
+	}
+	if _, ok := arr.Err().(LimitError); !ok {
+		t.Fatalf("expected LimitError, got %v", arr.Err())
+	}
+}
+
+func Test_clientStreamArray(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, booksRespXML)
+	}))
+	defer ts.Close()
+
+	client, err := NewClient(ts.URL, nil)
+	if err != nil {
+		t.Fatalf("Can't create client: %v", err)
+	}
+	defer client.Close()
+
+	arr, err := client.StreamArray("export.books", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer arr.Close()
+
+	var titles []string
+	for arr.Next() {
+		var b book
+		if err := arr.Decode(&b); err != nil {
+			t.Fatalf("decode error: %v", err)
+		}
+		titles = append(titles, b.Title)
+	}
+	if err := arr.Err(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(titles) != 3 || titles[2] != "Anna Karenina" {
+		t.Fatalf("unexpected result: %v", titles)
+	}
+}
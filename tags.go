@@ -0,0 +1,58 @@
+package xmlrpc
+
+import (
+	"strings"
+)
+
+// tagOptions is the string following a comma in a struct field's "xmlrpc"
+// tag, or the empty string.
+type tagOptions string
+
+// parseTag splits a struct field's xmlrpc tag into its name and comma-separated
+// options.
+func parseTag(tag string) (string, tagOptions) {
+	if idx := strings.Index(tag, ","); idx != -1 {
+		return tag[:idx], tagOptions(tag[idx+1:])
+	}
+	return tag, tagOptions("")
+}
+
+// Contains reports whether a comma-separated list of options contains a
+// particular option.
+func (o tagOptions) Contains(option string) bool {
+	if len(o) == 0 {
+		return false
+	}
+
+	s := string(o)
+	for s != "" {
+		var next string
+		if i := strings.Index(s, ","); i >= 0 {
+			s, next = s[:i], s[i+1:]
+		}
+		if s == option {
+			return true
+		}
+		s = next
+	}
+
+	return false
+}
+
+// Get returns the value of option given as "key=value" in a comma-separated
+// list of options. The value can't contain commas.
+func (o tagOptions) Get(key string) (string, bool) {
+	s := string(o)
+	for s != "" {
+		var next string
+		if i := strings.Index(s, ","); i >= 0 {
+			s, next = s[:i], s[i+1:]
+		}
+		if strings.HasPrefix(s, key+"=") {
+			return s[len(key)+1:], true
+		}
+		s = next
+	}
+
+	return "", false
+}
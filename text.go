@@ -0,0 +1,48 @@
+package xmlrpc
+
+import (
+	"encoding"
+	"reflect"
+)
+
+var (
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// textMarshaler returns val as encoding.TextMarshaler, if val or a pointer to
+// it implements the interface. time.Time is excluded, as it has its own
+// XML-RPC type.
+func textMarshaler(val reflect.Value) (encoding.TextMarshaler, bool) {
+	if !val.IsValid() || val.Type() == timeType || !val.CanInterface() {
+		return nil, false
+	}
+
+	if val.Type().Implements(textMarshalerType) {
+		if val.Kind() == reflect.Ptr && val.IsNil() {
+			return nil, false
+		}
+		return val.Interface().(encoding.TextMarshaler), true
+	}
+
+	if val.CanAddr() && reflect.PtrTo(val.Type()).Implements(textMarshalerType) {
+		return val.Addr().Interface().(encoding.TextMarshaler), true
+	}
+
+	return nil, false
+}
+
+// textUnmarshaler returns a pointer to val as encoding.TextUnmarshaler, if it
+// implements the interface. time.Time is excluded, as it has its own XML-RPC
+// type.
+func textUnmarshaler(val reflect.Value) (encoding.TextUnmarshaler, bool) {
+	if val.Kind() == reflect.Interface || val.Type() == timeType || !val.CanAddr() {
+		return nil, false
+	}
+
+	if reflect.PtrTo(val.Type()).Implements(textUnmarshalerType) {
+		return val.Addr().Interface().(encoding.TextUnmarshaler), true
+	}
+
+	return nil, false
+}